@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	gethost "gethost/internal"
+)
+
+// zoneInfo is what the admin API tells about one zone.
+type zoneInfo struct {
+	Zone        string     `json:"zone"`
+	Disabled    bool       `json:"disabled"`
+	NS          string     `json:"ns"`
+	Serial      uint32     `json:"serial"`
+	Records     int        `json:"records"`
+	LoadedAt    *time.Time `json:"loaded_at"`
+	LastAttempt *time.Time `json:"last_attempt"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastError   string     `json:"last_error"`
+	Transfers   uint64     `json:"transfers"`
+	Errors      uint64     `json:"errors"`
+	History     []struct {
+		Time     time.Time `json:"time"`
+		Duration string    `json:"duration"`
+		NS       string    `json:"ns"`
+		Serial   uint32    `json:"serial"`
+		Records  int       `json:"records"`
+		Error    string    `json:"error"`
+	} `json:"history"`
+}
+
+// adminActions is the admin subcommands that change a zone, and their method and path below the zone.
+var adminActions = map[string][2]string{
+	"refresh": {"POST", "/refresh"},
+	"disable": {"POST", "/disable"},
+	"enable":  {"POST", "/enable"},
+	"drop":    {"DELETE", "/cache"},
+}
+
+const adminUsage = "usage: admin zones | admin zone ZONE | admin refresh|disable|enable|drop ZONE"
+
+// runAdmin uses the admin API of the server, it requires an admin token.
+func runAdmin(ctx context.Context, args []string, config *gethost.Config) error {
+	if len(args) == 0 {
+		return errors.New(adminUsage)
+	}
+	if args[0] == "zones" {
+		body, err := serverGet(ctx, "/admin/zones", config)
+		if err != nil {
+			return err
+		}
+		zones := []zoneInfo{}
+		if err := json.Unmarshal(body, &zones); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ZONE\tSTATE\tSERIAL\tRECORDS\tLAST SUCCESS\tLAST ERROR")
+		for _, z := range zones {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", z.Zone, zoneState(z), z.Serial, z.Records, formatTime(z.LastSuccess), z.LastError)
+		}
+		return tw.Flush()
+	}
+
+	if len(args) != 2 {
+		return errors.New(adminUsage)
+	}
+	path := "/admin/zones/" + neturl.PathEscape(args[1])
+	method := "GET"
+	if args[0] != "zone" {
+		action, ok := adminActions[args[0]]
+		if !ok {
+			return errors.New(adminUsage)
+		}
+		method = action[0]
+		path += action[1]
+	}
+	body, err := serverDo(ctx, method, path, nil, config)
+	if err != nil {
+		return err
+	}
+	z := zoneInfo{}
+	if err := json.Unmarshal(body, &z); err != nil {
+		return err
+	}
+	printZoneInfo(z)
+	return nil
+}
+
+func zoneState(z zoneInfo) string {
+	if z.Disabled {
+		return "disabled"
+	}
+	if z.LoadedAt == nil {
+		return "not loaded"
+	}
+	return "enabled"
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func printZoneInfo(z zoneInfo) {
+	fmt.Printf("Zone:         %s\n", z.Zone)
+	fmt.Printf("State:        %s\n", zoneState(z))
+	fmt.Printf("NS:           %s\n", z.NS)
+	fmt.Printf("Serial:       %d\n", z.Serial)
+	fmt.Printf("Records:      %d\n", z.Records)
+	fmt.Printf("Loaded:       %s\n", formatTime(z.LoadedAt))
+	fmt.Printf("Last attempt: %s\n", formatTime(z.LastAttempt))
+	fmt.Printf("Last success: %s\n", formatTime(z.LastSuccess))
+	fmt.Printf("Last error:   %s\n", z.LastError)
+	fmt.Printf("Transfers:    %d (%d errors)\n", z.Transfers, z.Errors)
+	if len(z.History) == 0 {
+		return
+	}
+	fmt.Println("History:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, h := range z.History {
+		result := fmt.Sprintf("serial %d, %d records", h.Serial, h.Records)
+		if h.Error != "" {
+			result = "error: " + h.Error
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", h.Time.Local().Format(time.RFC3339), h.Duration, h.NS, result)
+	}
+	tw.Flush()
+}
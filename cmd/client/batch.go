@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	gethost "gethost/internal"
+)
+
+// maxBatch is the most queries the server takes in one batch, more are sent in several requests.
+const maxBatch = 1000
+
+// batchTries is how many times batches are sent before giving up when the cache of the server changes between them.
+const batchTries = 3
+
+// batchQuery is one search in a batch, see /v2/hosts/batch.
+type batchQuery struct {
+	Q      string   `json:"q"`
+	Mode   string   `json:"mode,omitempty"`
+	Zones  []string `json:"zone,omitempty"`
+	Types  []string `json:"type,omitempty"`
+	Tags   []string `json:"tag,omitempty"`
+	Limit  int      `json:"limit,omitempty"`
+	Detail bool     `json:"detail,omitempty"`
+}
+
+// batchResult is the result of one query, Results is names or detailed records.
+type batchResult struct {
+	Q       string          `json:"q"`
+	Results json.RawMessage `json:"results"`
+	Total   int             `json:"total"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// runBatch searches for every line on stdin and prints the query and each matching name, separated
+// by a tab. A line that is a JSON object is a query with its own options, e.g. {"q":"web","mode":"prefix"}.
+// It fails if any query had no match or could not be run.
+func runBatch(ctx context.Context, args []string, config *gethost.Config) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	mode := fs.String("mode", gethost.ModeAuto, "How to match lines that are not JSON, one of "+strings.Join(gethost.Modes, ", "))
+	limit := fs.Int("limit", 0, "Most names for each query, 0 is the default of the server")
+	asJSON := fs.Bool("json", false, "Print the result of each query as a line of JSON")
+	fs.Parse(args)
+
+	queries, err := readQueries(os.Stdin, *mode, *limit)
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		return nil
+	}
+
+	results, err := batchFromServer(ctx, queries, config)
+	if err != nil {
+		log.Println(err)
+		results = batchFromDNS(ctx, queries, config)
+	}
+
+	failed := 0
+	for _, res := range results {
+		if *asJSON {
+			b, _ := json.Marshal(res)
+			fmt.Println(string(b))
+		} else {
+			printBatchResult(res)
+		}
+		switch {
+		case res.Error != "":
+			log.Printf("%s: %s", res.Q, res.Error)
+			failed++
+		case res.Total == 0:
+			log.Printf("%s: no match", res.Q)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queries had no match or failed", failed, len(results))
+	}
+	return nil
+}
+
+// readQueries reads one query per line, with mode and limit unless the line is JSON with its own.
+// Empty lines and lines that begin with # are skipped.
+func readQueries(r io.Reader, mode string, limit int) ([]batchQuery, error) {
+	queries := []batchQuery{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		q := batchQuery{Q: line}
+		if strings.HasPrefix(line, "{") {
+			q = batchQuery{}
+			dec := json.NewDecoder(strings.NewReader(line))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&q); err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+		}
+		if q.Mode == "" {
+			q.Mode = mode
+		}
+		if q.Limit == 0 {
+			q.Limit = limit
+		}
+		queries = append(queries, q)
+	}
+	return queries, scanner.Err()
+}
+
+// batchFromServer runs queries on the server, in batches of at most maxBatch. The batches must see the
+// same cache, so all are sent again if the generation of the cache changed between them.
+func batchFromServer(ctx context.Context, queries []batchQuery, config *gethost.Config) ([]batchResult, error) {
+	for try := 0; try < batchTries; try++ {
+		results, same, err := batchesFromServer(ctx, queries, config)
+		if err != nil || same {
+			return results, err
+		}
+	}
+	return nil, fmt.Errorf("the cache of the server changed during all %d tries of the batch", batchTries)
+}
+
+// batchesFromServer runs queries in batches of at most maxBatch, and returns false if they did not all
+// get the same generation of the cache.
+func batchesFromServer(ctx context.Context, queries []batchQuery, config *gethost.Config) ([]batchResult, bool, error) {
+	results := []batchResult{}
+	var generation uint64
+	for i := 0; len(queries) > 0; i++ {
+		n := len(queries)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		req, err := json.Marshal(struct {
+			Queries []batchQuery `json:"queries"`
+		}{queries[:n]})
+		if err != nil {
+			return nil, false, err
+		}
+		body, err := serverDo(ctx, "POST", "/v2/hosts/batch", req, config)
+		if err != nil {
+			return nil, false, err
+		}
+		resp := struct {
+			Results    []batchResult `json:"results"`
+			Generation uint64        `json:"generation"`
+		}{}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, false, err
+		}
+		if i == 0 {
+			generation = resp.Generation
+		} else if resp.Generation != generation {
+			return nil, false, nil
+		}
+		results = append(results, resp.Results...)
+		queries = queries[n:]
+	}
+	return results, true, nil
+}
+
+// batchFromDNS runs queries on names from one transfer of all zones, when the server can not be used.
+// Only the match mode and limit can be used without the server.
+func batchFromDNS(ctx context.Context, queries []batchQuery, config *gethost.Config) []batchResult {
+	names := namesFromDNS(ctx, config)
+	results := []batchResult{}
+	for _, q := range queries {
+		res := batchResult{Q: q.Q, Results: json.RawMessage("[]")}
+		if len(q.Zones) > 0 || len(q.Types) > 0 || len(q.Tags) > 0 || q.Detail {
+			res.Error = "zone, type, tag and detail need the server"
+			results = append(results, res)
+			continue
+		}
+		match, err := gethost.Matcher(q.Mode, q.Q)
+		if err != nil {
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+		found := []string{}
+		for _, name := range names {
+			if match(name) {
+				found = append(found, name)
+			}
+		}
+		res.Total = len(found)
+		if q.Limit > 0 && len(found) > q.Limit {
+			found = found[:q.Limit]
+		}
+		res.Results, _ = json.Marshal(found)
+		results = append(results, res)
+	}
+	return results
+}
+
+// printBatchResult prints the query and each name, and the addresses of detailed records.
+func printBatchResult(res batchResult) {
+	names := []string{}
+	if err := json.Unmarshal(res.Results, &names); err == nil {
+		for _, n := range names {
+			fmt.Printf("%s\t%s\n", res.Q, n)
+		}
+		return
+	}
+	details := []hostDetail{}
+	json.Unmarshal(res.Results, &details)
+	for _, d := range details {
+		fmt.Printf("%s\t%s\t%s\n", res.Q, d.Name, strings.Join(d.Addresses, ","))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	gethost "gethost/internal"
+)
+
+// batchServer answers batches with the query as the only name, and the generation from gen.
+func batchServer(t *testing.T, gen func(call int) uint64) (*httptest.Server, *int) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Queries []batchQuery `json:"queries"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if len(req.Queries) > maxBatch {
+			t.Errorf("got %d queries in a batch", len(req.Queries))
+		}
+		results := []batchResult{}
+		for _, q := range req.Queries {
+			results = append(results, batchResult{Q: q.Q, Results: json.RawMessage(fmt.Sprintf("[%q]", q.Q)), Total: 1})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results, "generation": gen(calls)})
+		calls++
+	}))
+	return srv, &calls
+}
+
+// serverConfig returns a configuration of the client for srv.
+func serverConfig(t *testing.T, srv *httptest.Server) *gethost.Config {
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &gethost.Config{ServerURL: "http://" + u.Hostname(), ServerPort: port, ClientTimeout: 5000, ClientCacheDir: "-"}
+}
+
+func manyQueries(n int) []batchQuery {
+	queries := []batchQuery{}
+	for i := 0; i < n; i++ {
+		queries = append(queries, batchQuery{Q: fmt.Sprintf("host-%d", i)})
+	}
+	return queries
+}
+
+func TestBatchFromServer(t *testing.T) {
+	queries := manyQueries(2*maxBatch + 1)
+
+	// The generation changes between the first and second batch, the second try sees one generation.
+	srv, calls := batchServer(t, func(call int) uint64 {
+		if call == 0 {
+			return 1
+		}
+		return 2
+	})
+	defer srv.Close()
+	config := serverConfig(t, srv)
+
+	results, err := batchFromServer(context.Background(), queries, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(queries) || results[0].Q != "host-0" || results[len(results)-1].Q != queries[len(queries)-1].Q {
+		t.Errorf("got %d results, want one for each of %d queries in order", len(results), len(queries))
+	}
+	if *calls != 5 {
+		t.Errorf("got %d requests, want 2 of the first try and 3 of the second", *calls)
+	}
+}
+
+func TestBatchFromServerChanging(t *testing.T) {
+	srv, calls := batchServer(t, func(call int) uint64 { return uint64(call) })
+	defer srv.Close()
+	config := serverConfig(t, srv)
+
+	if _, err := batchFromServer(context.Background(), manyQueries(maxBatch+1), config); err == nil {
+		t.Error("batches of always changing generations did not fail")
+	}
+	if *calls != 2*batchTries {
+		t.Errorf("got %d requests, want %d", *calls, 2*batchTries)
+	}
+
+	// One batch can not see different generations.
+	if _, err := batchFromServer(context.Background(), manyQueries(maxBatch), config); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gethost "gethost/internal"
+)
+
+// cacheMaxAge is how long a cached response is kept after it was last used.
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// cachedResponse is a response from the server, that is sent again with 304 Not Modified
+// when its ETag is still current.
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// cacheDir returns the directory responses are kept in, and false if responses are not cached.
+func cacheDir(config *gethost.Config) (string, bool) {
+	switch config.ClientCacheDir {
+	case "-":
+		return "", false
+	case "":
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", false
+		}
+		return filepath.Join(dir, "gethost"), true
+	}
+	return config.ClientCacheDir, true
+}
+
+// cacheFile returns the file of the response to url. The token is part of the key,
+// as tokens may see different names.
+func cacheFile(dir string, url string, token string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + token))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// loadCached returns the cached response in file, or nil if there is none.
+func loadCached(file string) *cachedResponse {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	c := &cachedResponse{}
+	if err := json.Unmarshal(b, c); err != nil || c.ETag == "" {
+		return nil
+	}
+	return c
+}
+
+// touchCached marks the cached response in file as used, so it is kept.
+func touchCached(file string) {
+	now := time.Now()
+	os.Chtimes(file, now, now)
+}
+
+// saveCached writes c to file, and removes responses in the same directory that have not been used for cacheMaxAge.
+func saveCached(file string, c cachedResponse) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, fi := range files {
+		if strings.HasSuffix(fi.Name(), ".json") && time.Since(fi.ModTime()) > cacheMaxAge {
+			os.Remove(filepath.Join(dir, fi.Name()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	neturl "net/url"
+	"time"
+
+	gethost "gethost/internal"
+)
+
+// changeSet is the difference for one zone between two updates of the server cache.
+type changeSet struct {
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Zone      string    `json:"zone"`
+	OldSerial uint32    `json:"old_serial"`
+	Serial    uint32    `json:"serial"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+	Changed   []string  `json:"changed"`
+}
+
+// runChanges prints what have changed in the server cache, one name per line
+// prefixed with + for added, - for removed and ~ for changed.
+func runChanges(ctx context.Context, args []string, config *gethost.Config) error {
+	fs := flag.NewFlagSet("changes", flag.ExitOnError)
+	since := fs.String("since", "24h", "Show changes since RFC3339 timestamp, unix timestamp or duration back in time")
+	fs.Parse(args)
+
+	body, err := serverGet(ctx, "/changes?since="+neturl.QueryEscape(*since), config)
+	if err != nil {
+		return err
+	}
+	sets := []changeSet{}
+	if err := json.Unmarshal(body, &sets); err != nil {
+		return err
+	}
+
+	for _, cs := range sets {
+		t := cs.Time.Local().Format(time.RFC3339)
+		for _, n := range cs.Added {
+			fmt.Printf("%s %s %d + %s\n", t, cs.Zone, cs.Serial, n)
+		}
+		for _, n := range cs.Removed {
+			fmt.Printf("%s %s %d - %s\n", t, cs.Zone, cs.Serial, n)
+		}
+		for _, n := range cs.Changed {
+			fmt.Printf("%s %s %d ~ %s\n", t, cs.Zone, cs.Serial, n)
+		}
+	}
+	return nil
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/miekg/dns"
@@ -23,17 +30,37 @@ import (
 	gethost "gethost/internal"
 )
 
+// subcommands is run instead of a host lookup when the first argument is their name.
+var subcommands = map[string]func(ctx context.Context, args []string, config *gethost.Config) error{
+	"changes":    runChanges,
+	"admin":      runAdmin,
+	"batch":      runBatch,
+	"completion": runCompletion,
+	"ssh":        runSSH,
+}
+
+func init() {
+	// Set here, as runComplete uses subcommands.
+	subcommands["__complete"] = runComplete
+}
+
+// configPath is the configuration file given with -configfile.
+var configPath string
+
 func main() {
 
 	useTracing := flag.Bool("tracing", false, "Enable tracing of calls.")
 	useNC := flag.Bool("nc", false, "No Cache. Force reload of cache")
 	getAllHosts := flag.Bool("a", false, "Get all hosts")
+	pickOne := flag.Bool("pick", false, "Pick among the matches when several match and stdout is a terminal, instead of printing them all")
+	mode := flag.String("mode", gethost.ModeAuto, "How to match hostname, one of "+strings.Join(gethost.Modes, ", "))
 	configFile := flag.String("configfile", "", "Configuation file")
 	goversionflag.PrintVersionAndExit()
 
 	if *configFile == "" {
 		log.Fatalln("Need configuration file.")
 	}
+	configPath = *configFile
 
 	config, err := gethost.NewConfig(configFile)
 	if err != nil {
@@ -41,17 +68,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	var hostToGet string
-	flagsLeftover := flag.Args()
-	if len(flagsLeftover) > 0 {
-		hostToGet = flagsLeftover[0]
-	}
-
-	if hostToGet == "" && *getAllHosts == false {
-		log.Println("Need part of hostname to match against")
-		os.Exit(1)
-	}
-
 	var tracer opentracing.Tracer
 	var closer io.Closer
 
@@ -63,21 +79,50 @@ func main() {
 	}
 	opentracing.SetGlobalTracer(tracer)
 
+	args := flag.Args()
+	if len(args) > 0 {
+		if sub, ok := subcommands[args[0]]; ok {
+			span := tracer.StartSpan(args[0])
+			ctx := opentracing.ContextWithSpan(context.Background(), span)
+			err := sub(ctx, args[1:], config)
+			span.Finish()
+			if err != nil {
+				log.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Several arguments are several terms that all must match, e.g. "web dc2".
+	hostToGet := strings.Join(args, " ")
+
+	if hostToGet == "" && *getAllHosts == false {
+		log.Println("Need part of hostname to match against")
+		os.Exit(1)
+	}
+
 	span := tracer.StartSpan("Get-hosts")
 	defer span.Finish()
 	ctx := opentracing.ContextWithSpan(context.Background(), span)
 
-	// Server uses hostname/nc to force reload of cache.
-	if *useNC == true {
-		hostToGet = hostToGet + "/nc"
-	}
-	r, err := getFromServer(ctx, hostToGet, config)
+	r, records, err := lookup(ctx, hostToGet, *mode, *useNC, config)
 	if err != nil {
 		log.Println(err)
+		os.Exit(1)
 	}
-	// No match from server, do lookup ourself
-	if r == nil {
-		r = getFromDNS(ctx, hostToGet, config)
+
+	// Let the user pick when asked to, there are several matches and someone is looking.
+	if *pickOne && len(r) > 1 && isTerminal(os.Stdout) {
+		chosen, err := pick(r, hostPreview(ctx, records, config), true)
+		switch err {
+		case nil:
+			r = chosen
+		case errNoTerminal:
+		default:
+			log.Println(err)
+			os.Exit(1)
+		}
 	}
 
 	for _, i := range r {
@@ -86,7 +131,28 @@ func main() {
 
 }
 
-func getFromDNS(ctx context.Context, hostToGet string, config *gethost.Config) []string {
+// lookup returns the names that match query from the server, or from AXFR if the server can not be used.
+// Names from AXFR are returned with the records of all names.
+func lookup(ctx context.Context, query string, mode string, noCache bool, config *gethost.Config) ([]string, map[string][]dns.RR, error) {
+	match, err := gethost.Matcher(mode, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := getFromServer(ctx, query, mode, noCache, config)
+	if err != nil {
+		log.Println(err)
+	}
+	// No match from server, do lookup ourself
+	if r == nil {
+		names, records := getFromDNS(ctx, match, config)
+		return names, records, nil
+	}
+	return r, nil, nil
+}
+
+// getFromDNS does AXFR of all zones and returns the sorted names that match, and the records of all names.
+func getFromDNS(ctx context.Context, match func(name string) bool, config *gethost.Config) ([]string, map[string][]dns.RR) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "getFromDNS")
 	defer span.Finish()
 
@@ -97,7 +163,7 @@ func getFromDNS(ctx context.Context, hostToGet string, config *gethost.Config) [
 
 	for _, s := range zones {
 		z := s.Header().Name
-		go gethost.GetRRforZone(ctx, z, hostToGet, c, config)
+		go gethost.GetRRforZone(ctx, z, "", c, config)
 	}
 
 	for range zones {
@@ -109,47 +175,197 @@ func getFromDNS(ctx context.Context, hostToGet string, config *gethost.Config) [
 
 	keys := []string{}
 	for k := range dnsRR {
-		keys = append(keys, k)
+		if match(k) {
+			keys = append(keys, k)
+		}
 	}
 
 	sort.Strings(keys)
-	return keys
+	return keys, dnsRR
+
+}
+
+// namesFromDNS does AXFR of all zones and returns all sorted names.
+func namesFromDNS(ctx context.Context, config *gethost.Config) []string {
+	names, _ := getFromDNS(ctx, func(string) bool { return true }, config)
+	return names
+}
+
+// hostDetail is the detailed records of a name on the server.
+type hostDetail struct {
+	Name      string            `json:"name"`
+	Zone      string            `json:"zone"`
+	Addresses []string          `json:"addresses"`
+	CNAME     string            `json:"cname"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// hostsResponse is the envelope of /v2/hosts on the server.
+type hostsResponse struct {
+	Results    []string `json:"results"`
+	Total      int      `json:"total"`
+	CacheAge   int      `json:"cache_age"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+// getFromServer returns all names that match from the server, following all pages.
+func getFromServer(ctx context.Context, hostToGet string, mode string, noCache bool, config *gethost.Config) ([]string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "getFromServer")
+	defer span.Finish()
+
+	params := neturl.Values{}
+	params.Set("q", hostToGet)
+	params.Set("mode", mode)
+	params.Set("limit", "10000")
+	if noCache == true {
+		params.Set("nc", "true")
+	}
+
+	slice := []string{}
+	for {
+		body, err := serverGet(ctx, "/v2/hosts?"+params.Encode(), config)
+		if err != nil {
+			return nil, err
+		}
+		resp := hostsResponse{}
+		err = json.Unmarshal(body, &resp)
+		if err != nil {
+			return nil, err
+		}
+		slice = append(slice, resp.Results...)
+		if resp.NextCursor == "" {
+			break
+		}
+		params.Set("cursor", resp.NextCursor)
+		params.Del("nc")
+	}
 
+	return slice, nil
 }
 
-func getFromServer(ctx context.Context, hostToGet string, config *gethost.Config) ([]string, error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "getFromServer")
+// serverGet does a GET request for path against the configured server and returns the body.
+func serverGet(ctx context.Context, path string, config *gethost.Config) ([]byte, error) {
+	return serverDo(ctx, "GET", path, nil, config)
+}
+
+// serverDo does a request with method for path against the configured server and returns the body.
+// A request body is sent as JSON.
+func serverDo(ctx context.Context, method string, path string, reqBody []byte, config *gethost.Config) ([]byte, error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "serverDo")
 	defer span.Finish()
 
-	url := config.ServerURL + ":" + strconv.Itoa(config.ServerPort) + "/hosts/" + hostToGet
-	req, err := http.NewRequest("GET", url, nil)
+	url := serverURL(config) + path
+	var r io.Reader
+	if reqBody != nil {
+		r = bytes.NewReader(reqBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	// GET responses are kept, and revalidated with their ETag.
+	file := ""
+	var cached *cachedResponse
+	if dir, ok := cacheDir(config); ok && method == "GET" {
+		file = cacheFile(dir, url, config.Token)
+		if cached = loadCached(file); cached != nil {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
 	}
 
 	ext.SpanKindRPCClient.Set(span)
 	ext.HTTPUrl.Set(span, url)
-	ext.HTTPMethod.Set(span, "GET")
+	ext.HTTPMethod.Set(span, method)
 	span.Tracer().Inject(
 		span.Context(),
 		opentracing.HTTPHeaders,
 		opentracing.HTTPHeadersCarrier(req.Header),
 	)
 
-	client := &http.Client{Timeout: 2000 * time.Millisecond}
+	client, err := httpClient(config)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		touchCached(file)
+		return cached.Body, nil
+	}
 	body, err := ioutil.ReadAll(resp.Body)
-
-	slice := []string{}
-	err = json.Unmarshal(body, &slice)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if etag := resp.Header.Get("ETag"); file != "" && etag != "" {
+		if err := saveCached(file, cachedResponse{ETag: etag, Body: body}); err != nil && config.Verbose {
+			log.Println("Could not cache response:", err)
+		}
+	}
+	return body, nil
+}
 
-	return slice, nil
+// serverURL returns the URL of the configured server. Requests to a Unix domain socket
+// get a placeholder host, as httpClient connects to the socket.
+func serverURL(config *gethost.Config) string {
+	if _, ok := gethost.SocketPath(config.ServerURL); ok {
+		return "http://unix"
+	}
+	return config.ServerURL + ":" + strconv.Itoa(config.ServerPort)
+}
+
+// httpClient returns a client with the configured timeout, and for HTTPS the configured CA bundle and client certificate.
+// For a ServerURL of unix:/path/to/socket the client connects to the socket, without TLS.
+func httpClient(config *gethost.Config) (*http.Client, error) {
+	client := &http.Client{Timeout: time.Duration(config.ClientTimeout) * time.Millisecond}
+	if path, ok := gethost.SocketPath(config.ServerURL); ok {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		return client, nil
+	}
+	if config.ServerCAFile == "" && config.ClientCertFile == "" {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.ServerCAFile != "" {
+		pem, err := ioutil.ReadFile(config.ServerCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in " + config.ServerCAFile)
+		}
+	}
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	client.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	return client, nil
 }
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gethost "gethost/internal"
+)
+
+// completeLimit is the most names offered for one word.
+const completeLimit = 100
+
+// completeTimeout is how long completion waits for the server.
+const completeTimeout = 2 * time.Second
+
+// Kinds of commands that words are completed for.
+const (
+	kindClient = "client" // kindClient is this client, with its flags and subcommands.
+	kindSSH    = "ssh"    // kindSSH takes ssh options and [user@]host, like the s function in function.sh.
+	kindSCP    = "scp"    // kindSCP takes [user@]host: in any argument.
+)
+
+// sshArgOptions is the options of ssh that take an argument.
+const sshArgOptions = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// clientArgFlags is the flags of the client that take an argument.
+var clientArgFlags = map[string]bool{"configfile": true, "mode": true}
+
+// adminCommands is what the admin subcommand takes, see adminUsage.
+var adminCommands = []string{"zones", "zone", "refresh", "disable", "enable", "drop"}
+
+const completionUsage = "usage: completion [-ssh s,get_host] [-scp NAMES] bash|zsh|fish"
+
+// runCompletion prints a completion script for a shell. It completes the subcommands of the client,
+// and host names for the client and for wrappers of ssh and scp.
+func runCompletion(ctx context.Context, args []string, config *gethost.Config) error {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	ssh := fs.String("ssh", "s,get_host", "Comma separated commands that take ssh options and [user@]host")
+	scp := fs.String("scp", "", "Comma separated commands that take [user@]host: in any argument")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New(completionUsage)
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	conf, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+	commands := map[string][]string{
+		kindClient: {filepath.Base(os.Args[0])},
+		kindSSH:    splitList(*ssh),
+		kindSCP:    splitList(*scp),
+	}
+
+	var script string
+	switch fs.Arg(0) {
+	case "bash":
+		script = bashScript(bin, conf, commands)
+	case "zsh":
+		script = zshScript(bin, conf, commands)
+	case "fish":
+		script = fishScript(bin, conf, commands)
+	default:
+		return errors.New(completionUsage)
+	}
+	fmt.Print(script)
+	return nil
+}
+
+func splitList(s string) []string {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// posixQuote quotes s for bash and zsh.
+func posixQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func bashScript(bin, conf string, commands map[string][]string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `# Completion for gethost, generated by "%s completion bash".
+_gethost_complete()
+{
+    local kind=$1 line=${COMP_LINE:0:COMP_POINT} cur=${COMP_WORDS[COMP_CWORD]}
+    local -a words candidates
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] || ${#words[@]} == 0 ]] && words+=("")
+    # Bash splits user@host and host:path into several words, only the part after the last one is replaced.
+    local word=${words[${#words[@]}-1]}
+    local pre=${word%%"$cur"}
+    local IFS=$'\n'
+    candidates=($(%s -configfile %s __complete -kind "$kind" -- "${words[@]}" 2>/dev/null))
+    COMPREPLY=()
+    local c q
+    for c in "${candidates[@]}"; do
+        printf -v q '%%q' "${c#"$pre"}"
+        COMPREPLY+=("$q")
+        [[ $c == *: ]] && compopt -o nospace
+    done
+}
+`, commands[kindClient][0], posixQuote(bin), posixQuote(conf))
+	for _, kind := range []string{kindClient, kindSSH, kindSCP} {
+		if len(commands[kind]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "_gethost_%s() { _gethost_complete %s; }\n", kind, kind)
+		fmt.Fprintf(&b, "complete -o default -F _gethost_%s %s\n", kind, strings.Join(commands[kind], " "))
+	}
+	return b.String()
+}
+
+func zshScript(bin, conf string, commands map[string][]string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `# Completion for gethost, generated by "%s completion zsh". Load it after compinit.
+_gethost_complete() {
+  local kind=$1
+  local -a candidates
+  candidates=(${(f)"$(%s -configfile %s __complete -kind $kind -- "${(@)words[1,CURRENT]}" 2>/dev/null)"})
+  if (( ! ${#candidates} )); then
+    _default
+  elif [[ $kind == scp ]]; then
+    compadd -U -S '' -- $candidates
+  else
+    compadd -U -- $candidates
+  fi
+}
+`, commands[kindClient][0], posixQuote(bin), posixQuote(conf))
+	for _, kind := range []string{kindClient, kindSSH, kindSCP} {
+		if len(commands[kind]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "_gethost_%s() { _gethost_complete %s; }\n", kind, kind)
+		fmt.Fprintf(&b, "compdef _gethost_%s %s\n", kind, strings.Join(commands[kind], " "))
+	}
+	return b.String()
+}
+
+func fishScript(bin, conf string, commands map[string][]string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `# Completion for gethost, generated by "%s completion fish".
+function __gethost_complete
+    set -l cur (commandline -ct)
+    %s -configfile %s __complete -kind $argv[1] -- (commandline -opc) "$cur" 2>/dev/null
+end
+`, commands[kindClient][0], fishQuote(bin), fishQuote(conf))
+	for _, kind := range []string{kindClient, kindSSH, kindSCP} {
+		// Files are only offered for scp, the others take host names.
+		files := " -f"
+		if kind == kindSCP {
+			files = ""
+		}
+		for _, c := range commands[kind] {
+			fmt.Fprintf(&b, "complete -c %s%s -a '(__gethost_complete %s)'\n", fishQuote(c), files, kind)
+		}
+	}
+	return b.String()
+}
+
+// runComplete prints the completions of the last word, one per line, for the completion scripts.
+// The words are the command line up to the cursor, the command first.
+func runComplete(ctx context.Context, args []string, config *gethost.Config) error {
+	fs := flag.NewFlagSet("__complete", flag.ExitOnError)
+	kind := fs.String("kind", kindClient, "What the command is, client, ssh or scp")
+	fs.Parse(args)
+	words := fs.Args()
+	if len(words) < 2 {
+		return nil
+	}
+
+	var candidates []string
+	switch *kind {
+	case kindSSH:
+		candidates = completeSSH(ctx, words[1:], config)
+	case kindSCP:
+		candidates = completeSCP(ctx, words[1:], config)
+	default:
+		candidates = completeClient(ctx, words[1:], config)
+	}
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// completeClient completes the arguments of the client: subcommands, or host names for a lookup.
+func completeClient(ctx context.Context, args []string, config *gethost.Config) []string {
+	cur := args[len(args)-1]
+	if strings.HasPrefix(cur, "-") {
+		return nil
+	}
+	// Skip the flags before the subcommand or the terms of a lookup.
+	i := 0
+	for ; i < len(args)-1 && strings.HasPrefix(args[i], "-"); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		if clientArgFlags[name] {
+			i++
+		}
+	}
+	if i >= len(args)-1 {
+		if i > len(args)-1 {
+			// cur is the argument of a flag.
+			return nil
+		}
+		commands := []string{}
+		for name := range subcommands {
+			if strings.HasPrefix(name, cur) && !strings.HasPrefix(name, "__") {
+				commands = append(commands, name)
+			}
+		}
+		sort.Strings(commands)
+		return append(commands, completeHost(ctx, "", cur, "", config)...)
+	}
+
+	rest := args[i+1:]
+	switch args[i] {
+	case "completion":
+		return withPrefix([]string{"bash", "zsh", "fish"}, cur)
+	case "admin":
+		if len(rest) == 1 {
+			return withPrefix(adminCommands, cur)
+		}
+		if len(rest) == 2 && rest[0] != "zones" {
+			zones := []string{}
+			for _, z := range config.Zones {
+				zones = append(zones, strings.TrimSuffix(z, "."))
+			}
+			return withPrefix(zones, cur)
+		}
+		return nil
+	case "ssh":
+		// Skip the flags of the subcommand, the rest is like ssh.
+		j := 0
+		for ; j < len(rest)-1 && strings.HasPrefix(rest[j], "-"); j++ {
+			if sshArgFlags[strings.TrimLeft(rest[j], "-")] {
+				j++
+			}
+		}
+		if j > len(rest)-1 {
+			return nil
+		}
+		return completeSSH(ctx, rest[j:], config)
+	}
+	if _, ok := subcommands[args[i]]; ok {
+		return nil
+	}
+	return completeHost(ctx, "", cur, "", config)
+}
+
+// completeSSH completes [user@]host for the first argument that is not an option of ssh.
+func completeSSH(ctx context.Context, args []string, config *gethost.Config) []string {
+	for i := 0; i < len(args)-1; i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			// The host is already given.
+			return nil
+		}
+		if len(a) == 2 && strings.ContainsRune(sshArgOptions, rune(a[1])) {
+			i++
+		}
+	}
+	cur := args[len(args)-1]
+	if strings.HasPrefix(cur, "-") {
+		return nil
+	}
+	user, host := splitUser(cur)
+	return completeHost(ctx, user, host, "", config)
+}
+
+// completeSCP completes [user@]host: for an argument that is not yet a path.
+func completeSCP(ctx context.Context, args []string, config *gethost.Config) []string {
+	cur := args[len(args)-1]
+	if strings.HasPrefix(cur, "-") || strings.ContainsAny(cur, ":/") {
+		return nil
+	}
+	user, host := splitUser(cur)
+	return completeHost(ctx, user, host, ":", config)
+}
+
+// splitUser splits user@host into "user@" and host.
+func splitUser(word string) (string, string) {
+	if i := strings.LastIndex(word, "@"); i >= 0 {
+		return word[:i+1], word[i+1:]
+	}
+	return "", word
+}
+
+func withPrefix(list []string, prefix string) []string {
+	ret := []string{}
+	for _, e := range list {
+		if strings.HasPrefix(e, prefix) {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// completeHost returns the names for word from the server, between prefix and suffix. If the server
+// does not answer within completeTimeout, the names it gave for word before are used. There is no
+// transfer of the zones, that would be too slow for every TAB.
+func completeHost(ctx context.Context, prefix, word, suffix string, config *gethost.Config) []string {
+	ctx, cancel := context.WithTimeout(ctx, completeTimeout)
+	defer cancel()
+	path := completePath(word)
+	body, err := serverGet(ctx, path, config)
+	if err != nil {
+		body = completeFromCache(path, config)
+	}
+	ret := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		ret = append(ret, prefix+scanner.Text()+suffix)
+	}
+	return ret
+}
+
+// completePath is the path on the server of the names for word.
+func completePath(word string) string {
+	return "/v2/complete?limit=" + strconv.Itoa(completeLimit) + "&q=" + neturl.QueryEscape(word)
+}
+
+// completeFromCache returns the response of the server to path that is kept on disk, or nil.
+func completeFromCache(path string, config *gethost.Config) []byte {
+	dir, ok := cacheDir(config)
+	if !ok {
+		return nil
+	}
+	cached := loadCached(cacheFile(dir, serverURL(config)+path, config.Token))
+	if cached == nil {
+		return nil
+	}
+	return cached.Body
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	gethost "gethost/internal"
+)
+
+var testNames = []string{"db-1.example.tld", "web-1.example.tld", "web-2.example.tld"}
+
+// completeServer answers /v2/complete with the names in testNames that begin with q.
+func completeServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/complete" || r.URL.Query().Get("limit") != "100" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `W/"1"`)
+		for _, n := range withPrefix(testNames, r.URL.Query().Get("q")) {
+			w.Write([]byte(n + "\n"))
+		}
+	}))
+}
+
+func TestComplete(t *testing.T) {
+	srv := completeServer()
+	defer srv.Close()
+	config := serverConfig(t, srv)
+	config.Zones = []string{"example.tld.", "other.tld."}
+	hosts := []string{"web-1.example.tld", "web-2.example.tld"}
+
+	tests := []struct {
+		complete func(ctx context.Context, args []string, config *gethost.Config) []string
+		args     []string
+		want     []string
+	}{
+		{completeClient, []string{"c"}, []string{"changes", "completion"}},
+		{completeClient, []string{"we"}, hosts},
+		{completeClient, []string{"-"}, nil},
+		{completeClient, []string{"-configfile", "x"}, nil},
+		{completeClient, []string{"-mode", "exact", "we"}, hosts},
+		{completeClient, []string{"-nc", "d"}, []string{"db-1.example.tld"}},
+		{completeClient, []string{"completion", "z"}, []string{"zsh"}},
+		{completeClient, []string{"admin", ""}, adminCommands},
+		{completeClient, []string{"admin", "disable", "o"}, []string{"other.tld"}},
+		{completeClient, []string{"admin", "zones", ""}, nil},
+		{completeClient, []string{"admin", "zone", "example.tld", ""}, nil},
+		{completeClient, []string{"batch", "we"}, nil},
+		{completeClient, []string{"ssh", "-wait", "1m", "root@we"}, []string{"root@web-1.example.tld", "root@web-2.example.tld"}},
+		{completeClient, []string{"ssh", "-wait", ""}, nil},
+		{completeClient, []string{"ssh", "-mode", "prefix", "d"}, []string{"db-1.example.tld"}},
+		{completeClient, []string{"ssh", "web-1.example.tld", "-p", ""}, nil},
+		{completeClient, []string{"web", "d"}, []string{"db-1.example.tld"}},
+
+		{completeSSH, []string{"we"}, hosts},
+		{completeSSH, []string{"admin@d"}, []string{"admin@db-1.example.tld"}},
+		{completeSSH, []string{"-p", "22", "-v", "we"}, hosts},
+		{completeSSH, []string{"-l"}, nil},
+		{completeSSH, []string{"-o", "Port=22", ""}, []string{"db-1.example.tld", "web-1.example.tld", "web-2.example.tld"}},
+		{completeSSH, []string{"web-1.example.tld", "l"}, nil},
+		{completeSSH, []string{"x"}, []string{}},
+
+		{completeSCP, []string{"file", "we"}, []string{"web-1.example.tld:", "web-2.example.tld:"}},
+		{completeSCP, []string{"u@d"}, []string{"u@db-1.example.tld:"}},
+		{completeSCP, []string{"web-1:/tmp"}, nil},
+		{completeSCP, []string{"./x"}, nil},
+		{completeSCP, []string{"-r"}, nil},
+	}
+	for _, tc := range tests {
+		got := tc.complete(context.Background(), tc.args, config)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestCompleteWithoutServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "complete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := completeServer()
+	config := serverConfig(t, srv)
+	config.ClientCacheDir = dir
+	config.Zones = []string{"example.tld."}
+	config.NS = "127.0.0.1"
+	want := []string{"web-1.example.tld", "web-2.example.tld"}
+	if got := completeHost(context.Background(), "", "we", "", config); !reflect.DeepEqual(got, want) {
+		t.Fatalf("from the server: got %q, want %q", got, want)
+	}
+	srv.Close()
+
+	// The names the server gave before are used, and other words get nothing instead of a transfer.
+	if got := completeHost(context.Background(), "", "we", "", config); !reflect.DeepEqual(got, want) {
+		t.Errorf("from the cache: got %q, want %q", got, want)
+	}
+	if got := completeHost(context.Background(), "", "d", "", config); len(got) != 0 {
+		t.Errorf("not cached: got %q, want nothing", got)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 || !strings.HasSuffix(files[0].Name(), ".json") {
+		t.Errorf("got %d files in the cache, want 1", len(files))
+	}
+}
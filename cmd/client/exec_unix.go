@@ -0,0 +1,14 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// execCommand replaces the client with the command at path, it only returns if that fails.
+func execCommand(path string, argv []string) error {
+	return syscall.Exec(path, argv, os.Environ())
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/miekg/dns"
+
+	gethost "gethost/internal"
+)
+
+const (
+	pickerRows     = 12 // pickerRows is the most names shown at once.
+	previewWidth   = 60 // previewWidth is the narrowest terminal that has the preview beside the names.
+	previewBelow   = 3  // previewBelow is the lines of preview below the names on narrower terminals.
+	pickerMinWidth = 20
+)
+
+var (
+	errNoTerminal = errors.New("no terminal to pick on")
+	errNoneChosen = errors.New("no host was chosen")
+)
+
+// picker is an interactive list of names to choose from, drawn on the terminal below the cursor.
+type picker struct {
+	names   []string
+	multi   bool
+	preview func(name string) []string
+
+	tty      *os.File
+	query    []rune
+	matches  []string // matches is the names that match query, best first.
+	cursor   int      // cursor is the index of the highlighted match.
+	offset   int      // offset is the index of the first shown match.
+	marked   map[string]bool
+	previews map[string][]string
+	loading  map[string]bool
+	rows     int  // rows is the number of lines with names.
+	beside   bool // beside is true if the preview is beside the names, and not below.
+	results  chan previewResult
+	done     chan struct{} // done is closed when the picker is closed.
+}
+
+type previewResult struct {
+	name  string
+	lines []string
+}
+
+// pick lets the user choose among names on the terminal, with incremental fuzzy filtering and a preview
+// of the highlighted name. With multi, several names can be marked with Tab. It returns errNoTerminal if
+// there is no terminal, and errNoneChosen if the user cancelled.
+func pick(names []string, preview func(name string) []string, multi bool) ([]string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errNoTerminal
+	}
+	defer tty.Close()
+	state, err := makeRaw(tty)
+	if err != nil {
+		return nil, errNoTerminal
+	}
+	defer restoreTerminal(tty, state)
+
+	p := &picker{
+		names:    names,
+		multi:    multi,
+		preview:  preview,
+		tty:      tty,
+		marked:   map[string]bool{},
+		previews: map[string][]string{},
+		loading:  map[string]bool{},
+		results:  make(chan previewResult),
+	}
+	return p.run()
+}
+
+func (p *picker) run() ([]string, error) {
+	width, height := p.size()
+	p.rows = pickerRows
+	if len(p.names) < p.rows {
+		p.rows = len(p.names)
+	}
+	p.beside = width >= previewWidth
+	lines := 1 + p.rows
+	if !p.beside {
+		lines += previewBelow
+	}
+	if lines > height-1 && height > 2 {
+		p.rows -= lines - (height - 1)
+		if p.rows < 1 {
+			p.rows = 1
+		}
+		lines = height - 1
+	}
+	// Make room below the cursor, the terminal scrolls if it is at the bottom.
+	fmt.Fprint(p.tty, strings.Repeat("\n", lines-1))
+	if lines > 1 {
+		fmt.Fprintf(p.tty, "\x1b[%dA", lines-1)
+	}
+	defer fmt.Fprint(p.tty, "\r\x1b[J")
+
+	p.done = make(chan struct{})
+	defer close(p.done)
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := p.tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			b := append([]byte{}, buf[:n]...)
+			select {
+			case keys <- b:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	defer signal.Stop(resize)
+
+	p.filter()
+	for {
+		p.loadPreview()
+		p.draw()
+		select {
+		case b, ok := <-keys:
+			if !ok {
+				return nil, errNoneChosen
+			}
+			chosen, finished, err := p.key(b)
+			if finished {
+				return chosen, err
+			}
+		case r := <-p.results:
+			p.previews[r.name] = r.lines
+			delete(p.loading, r.name)
+		case <-resize:
+		}
+	}
+}
+
+// size returns the size of the terminal, or 80x24 if it is not known.
+func (p *picker) size() (int, int) {
+	w, h, err := terminalSize(p.tty)
+	if err != nil || w <= 0 || h <= 0 {
+		return 80, 24
+	}
+	return w, h
+}
+
+// filter sets matches to the names that match query, best first.
+func (p *picker) filter() {
+	type scored struct {
+		name  string
+		score int
+	}
+	found := []scored{}
+	for _, n := range p.names {
+		if s, ok := fuzzyScore(n, string(p.query)); ok {
+			found = append(found, scored{n, s})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score < found[j].score })
+	p.matches = make([]string, len(found))
+	for i, f := range found {
+		p.matches[i] = f.name
+	}
+	p.cursor, p.offset = 0, 0
+}
+
+// fuzzyScore returns how well name matches pattern, lower is better. Names that contain pattern come first,
+// then names that have the characters of pattern in order, with fewer characters between them first.
+func fuzzyScore(name, pattern string) (int, bool) {
+	name, pattern = strings.ToLower(name), strings.ToLower(pattern)
+	if i := strings.Index(name, pattern); i >= 0 {
+		return i, true
+	}
+	score, last, j := 0, -1, 0
+	for i := 0; i < len(name) && j < len(pattern); i++ {
+		if name[i] != pattern[j] {
+			continue
+		}
+		if last >= 0 {
+			score += i - last - 1
+		} else {
+			score += i
+		}
+		last = i
+		j++
+	}
+	if j < len(pattern) {
+		return 0, false
+	}
+	return len(name) + score, true
+}
+
+// move moves the cursor by n matches, and scrolls so that it is shown.
+func (p *picker) move(n int) {
+	p.cursor += n
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.rows {
+		p.offset = p.cursor - p.rows + 1
+	}
+}
+
+// key handles the keys in b. It returns true when the user has chosen or cancelled.
+func (p *picker) key(b []byte) ([]string, bool, error) {
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case 0x1b: // Escape, alone or first in the sequence of a special key.
+			if i+1 >= len(b) {
+				return nil, true, errNoneChosen
+			}
+			j := i + 1
+			if b[j] == '[' || b[j] == 'O' {
+				j++
+				for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+					j++
+				}
+			}
+			if j >= len(b) {
+				j = len(b) - 1
+			}
+			switch string(b[i+1 : j+1]) {
+			case "[A", "OA":
+				p.move(-1)
+			case "[B", "OB":
+				p.move(1)
+			case "[5~":
+				p.move(-p.rows)
+			case "[6~":
+				p.move(p.rows)
+			}
+			i = j
+		case 3, 7: // Ctrl-C and Ctrl-G
+			return nil, true, errNoneChosen
+		case 4: // Ctrl-D
+			if len(p.query) == 0 {
+				return nil, true, errNoneChosen
+			}
+		case '\r', '\n':
+			if chosen := p.chosen(); len(chosen) > 0 {
+				return chosen, true, nil
+			}
+		case '\t':
+			if p.multi && len(p.matches) > 0 {
+				n := p.matches[p.cursor]
+				if p.marked[n] {
+					delete(p.marked, n)
+				} else {
+					p.marked[n] = true
+				}
+				p.move(1)
+			}
+		case 16, 11: // Ctrl-P and Ctrl-K
+			p.move(-1)
+		case 14: // Ctrl-N
+			p.move(1)
+		case 0x7f, 8: // Backspace
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case 21: // Ctrl-U
+			p.query = nil
+			p.filter()
+		case 23: // Ctrl-W
+			q := strings.TrimRightFunc(string(p.query), unicode.IsSpace)
+			if k := strings.LastIndexFunc(q, unicode.IsSpace); k >= 0 {
+				q = q[:k+1]
+			} else {
+				q = ""
+			}
+			p.query = []rune(q)
+			p.filter()
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			if r >= 0x20 && r != utf8.RuneError {
+				p.query = append(p.query, r)
+				p.filter()
+			}
+			i += size - 1
+		}
+	}
+	return nil, false, nil
+}
+
+// chosen returns the marked names in their order, or the highlighted name if none is marked.
+func (p *picker) chosen() []string {
+	chosen := []string{}
+	for _, n := range p.names {
+		if p.marked[n] {
+			chosen = append(chosen, n)
+		}
+	}
+	if len(chosen) == 0 && len(p.matches) > 0 {
+		chosen = append(chosen, p.matches[p.cursor])
+	}
+	return chosen
+}
+
+// loadPreview starts loading the preview of the highlighted name, if it is not loaded.
+func (p *picker) loadPreview() {
+	if len(p.matches) == 0 || p.preview == nil {
+		return
+	}
+	n := p.matches[p.cursor]
+	if _, ok := p.previews[n]; ok || p.loading[n] {
+		return
+	}
+	p.loading[n] = true
+	go func() {
+		r := previewResult{n, p.preview(n)}
+		select {
+		case p.results <- r:
+		case <-p.done:
+		}
+	}()
+}
+
+// draw draws the prompt, the names and the preview, and puts the cursor after the query.
+func (p *picker) draw() {
+	width, _ := p.size()
+	if width < pickerMinWidth {
+		width = pickerMinWidth
+	}
+	listWidth := width
+	if p.beside {
+		listWidth = width / 2
+	}
+
+	status := fmt.Sprintf("%d/%d", len(p.matches), len(p.names))
+	if len(p.marked) > 0 {
+		status += fmt.Sprintf(" (%d marked)", len(p.marked))
+	}
+	prompt := "> " + displayText(string(p.query))
+	lines := []string{truncate(prompt+"  "+status, width)}
+
+	preview := []string{}
+	if len(p.matches) > 0 {
+		n := p.matches[p.cursor]
+		if l, ok := p.previews[n]; ok {
+			preview = l
+		} else {
+			preview = []string{"Loading..."}
+		}
+	}
+
+	for row := 0; row < p.rows; row++ {
+		i := p.offset + row
+		text := ""
+		if i < len(p.matches) {
+			mark := " "
+			if p.marked[p.matches[i]] {
+				mark = "*"
+			}
+			text = truncate(mark+" "+gethost.PlainName(p.matches[i]), listWidth-1)
+		}
+		line := text + strings.Repeat(" ", listWidth-1-utf8.RuneCountInString(text))
+		if i == p.cursor && i < len(p.matches) {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		if p.beside {
+			line += " │ "
+			if row < len(preview) {
+				line += truncate(displayText(preview[row]), width-listWidth-3)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if !p.beside {
+		for row := 0; row < previewBelow; row++ {
+			line := ""
+			if row < len(preview) {
+				line = truncate(displayText(preview[row]), width)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+	b.WriteString(strings.Join(lines, "\r\n"))
+	if len(lines) > 1 {
+		fmt.Fprintf(&b, "\x1b[%dA", len(lines)-1)
+	}
+	b.WriteString("\r")
+	if col := utf8.RuneCountInString(prompt); col > 0 && col < width {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	p.tty.WriteString(b.String())
+}
+
+// displayText replaces control characters in s, so it can not move the cursor or change the terminal.
+func displayText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// truncate shortens s to width characters.
+func truncate(s string, width int) string {
+	if width < 1 {
+		return ""
+	}
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// hostPreview returns the preview of the picker: the zone, addresses and annotations of a name from
+// the server, or the records of the name if the names are from AXFR.
+func hostPreview(ctx context.Context, records map[string][]dns.RR, config *gethost.Config) func(name string) []string {
+	return func(name string) []string {
+		if records != nil {
+			lines := []string{"Zone     " + zoneOf(name, config.Zones)}
+			for _, rr := range records[name] {
+				h := rr.Header()
+				lines = append(lines, fmt.Sprintf("%-8s %s", dns.TypeToString[h.Rrtype], strings.TrimPrefix(rr.String(), h.String())))
+			}
+			return lines
+		}
+		body, err := serverGet(ctx, "/hosts/"+neturl.PathEscape(name)+"/records", config)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		d := hostDetail{}
+		if err := json.Unmarshal(body, &d); err != nil {
+			return []string{err.Error()}
+		}
+		lines := []string{"Zone     " + d.Zone}
+		for _, a := range d.Addresses {
+			lines = append(lines, "Address  "+a)
+		}
+		if d.CNAME != "" {
+			lines = append(lines, "CNAME    "+d.CNAME)
+		}
+		tags := make([]string, 0, len(d.Tags))
+		for k, v := range d.Tags {
+			tags = append(tags, "Tag      "+k+"="+v)
+		}
+		sort.Strings(tags)
+		return append(lines, tags...)
+	}
+}
+
+// zoneOf returns the longest of zones that name is in.
+func zoneOf(name string, zones []string) string {
+	best := ""
+	for _, z := range zones {
+		zone := strings.TrimSuffix(z, ".")
+		if (name == zone || strings.HasSuffix(name, "."+zone)) && len(z) > len(best) {
+			best = z
+		}
+	}
+	return best
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		score   int
+		ok      bool
+	}{
+		{"web-1.example.tld", "", 0, true},
+		{"web-1.example.tld", "web", 0, true},
+		{"web-1.example.tld", "WEB-1", 0, true},
+		{"prod-web-1.example.tld", "web", 5, true},
+		{"web-1.example.tld", "w1", 17 + 3, true},
+		{"web-1.example.tld", "wbe", 17 + 1 + 3, true},
+		{"web-1.example.tld", "db", 0, false},
+		{"web-1.example.tld", "1w", 0, false},
+		{"web", "webb", 0, false},
+	}
+	for _, tc := range tests {
+		score, ok := fuzzyScore(tc.name, tc.pattern)
+		if score != tc.score || ok != tc.ok {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v, want %d, %v", tc.name, tc.pattern, score, ok, tc.score, tc.ok)
+		}
+	}
+}
+
+func testPicker(multi bool) *picker {
+	p := &picker{
+		names:  []string{"db-1.example.tld", "prod-web-1.example.tld", "web-1.example.tld", "web-2.example.tld"},
+		multi:  multi,
+		marked: map[string]bool{},
+		rows:   2,
+	}
+	p.filter()
+	return p
+}
+
+func TestPickerFilter(t *testing.T) {
+	p := testPicker(false)
+	p.key([]byte("web"))
+	want := []string{"web-1.example.tld", "web-2.example.tld", "prod-web-1.example.tld"}
+	if !reflect.DeepEqual(p.matches, want) {
+		t.Errorf("matches of web: got %q, want %q", p.matches, want)
+	}
+	p.key([]byte("2"))
+	if want := []string{"web-2.example.tld"}; !reflect.DeepEqual(p.matches, want) {
+		t.Errorf("matches of web2: got %q, want %q", p.matches, want)
+	}
+	p.key([]byte{0x7f})
+	if len(p.matches) != 3 {
+		t.Errorf("after backspace: got %q", p.matches)
+	}
+}
+
+func TestPickerKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		multi  bool
+		keys   []string
+		chosen []string
+		err    error
+	}{
+		{"enter", false, []string{"\r"}, []string{"db-1.example.tld"}, nil},
+		{"down", false, []string{"\x1b[B", "\r"}, []string{"prod-web-1.example.tld"}, nil},
+		{"down in application mode", false, []string{"\x1bOB\x1bOB", "\n"}, []string{"web-1.example.tld"}, nil},
+		{"down past the end", false, []string{"\x1b[B\x1b[B\x1b[B\x1b[B\x1b[B", "\r"}, []string{"web-2.example.tld"}, nil},
+		{"up past the start", false, []string{"\x0e\x0e", "\x1b[A\x10\x1b[A", "\r"}, []string{"db-1.example.tld"}, nil},
+		{"page down", false, []string{"\x1b[6~", "\r"}, []string{"web-1.example.tld"}, nil},
+		{"query", false, []string{"web2", "\r"}, []string{"web-2.example.tld"}, nil},
+		{"no match", false, []string{"xyz", "\r", "\x15", "\r"}, []string{"db-1.example.tld"}, nil},
+		{"ctrl-w", false, []string{"web-1 db", "\x17\x7f", "\r"}, []string{"web-1.example.tld"}, nil},
+		{"tab without multi", false, []string{"\t", "\r"}, []string{"db-1.example.tld"}, nil},
+		{"multi", true, []string{"\t\x1b[B\t", "\r"}, []string{"db-1.example.tld", "web-1.example.tld"}, nil},
+		{"unmark", true, []string{"\t", "\x1b[A", "\t", "\r"}, []string{"prod-web-1.example.tld"}, nil},
+		{"escape", false, []string{"\x1b"}, nil, errNoneChosen},
+		{"ctrl-c", false, []string{"web", "\x03"}, nil, errNoneChosen},
+		{"ctrl-d with query", false, []string{"web", "\x04", "\r"}, []string{"web-1.example.tld"}, nil},
+		{"ctrl-d", false, []string{"\x04"}, nil, errNoneChosen},
+	}
+	for _, tc := range tests {
+		p := testPicker(tc.multi)
+		var chosen []string
+		var finished bool
+		var err error
+		for _, k := range tc.keys {
+			if chosen, finished, err = p.key([]byte(k)); finished {
+				break
+			}
+		}
+		if !finished || !reflect.DeepEqual(chosen, tc.chosen) || err != tc.err {
+			t.Errorf("%s: got %q, %v, %v, want %q, true, %v", tc.name, chosen, finished, err, tc.chosen, tc.err)
+		}
+	}
+}
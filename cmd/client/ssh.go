@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	gethost "gethost/internal"
+)
+
+const sshUsage = "usage: ssh [-wait DURATION] [-mode MODE] [-ssh PATH] [user@]partial [ssh args]"
+
+// waitInterval is the time between tries to connect to the SSH port.
+const waitInterval = 5 * time.Second
+
+// sshArgFlags is the flags of the ssh subcommand that take an argument.
+var sshArgFlags = map[string]bool{"wait": true, "mode": true, "ssh": true}
+
+// runSSH resolves a partial host name, waits for its SSH port if asked to, and runs ssh to it
+// with the rest of the arguments unchanged.
+func runSSH(ctx context.Context, args []string, config *gethost.Config) error {
+	fs := flag.NewFlagSet("ssh", flag.ExitOnError)
+	wait := fs.Duration("wait", 0, "Wait at most this long for the SSH port of the host to open, e.g. 10m")
+	mode := fs.String("mode", gethost.ModeAuto, "How to match the partial name, one of "+strings.Join(gethost.Modes, ", "))
+	sshPath := fs.String("ssh", "ssh", "The ssh command to run")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New(sshUsage)
+	}
+	user, partial := splitUser(fs.Arg(0))
+	sshArgs := fs.Args()[1:]
+
+	host, err := resolveHost(ctx, partial, *mode, config)
+	if err != nil {
+		return err
+	}
+	if host != partial {
+		log.Printf("Connecting to %s", host)
+	}
+
+	path, err := exec.LookPath(*sshPath)
+	if err != nil {
+		return err
+	}
+	if *wait > 0 {
+		port := sshPort(path, user+host, sshArgs)
+		if err := waitForPort(ctx, host, port, *wait); err != nil {
+			return err
+		}
+	}
+	return execCommand(path, append([]string{*sshPath, user + host}, sshArgs...))
+}
+
+// resolveHost returns the one name that partial means. A name that matches exactly is taken, and
+// when several names match one is picked on the terminal. A name that does not match is taken
+// if it resolves, like ssh would.
+func resolveHost(ctx context.Context, partial, mode string, config *gethost.Config) (string, error) {
+	names, records, err := lookup(ctx, partial, mode, false, config)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range names {
+		if strings.EqualFold(n, strings.TrimSuffix(partial, ".")) {
+			return n, nil
+		}
+	}
+	switch len(names) {
+	case 0:
+		if _, err := net.LookupHost(partial); err == nil {
+			return partial, nil
+		}
+		return "", fmt.Errorf("no host matches %s", partial)
+	case 1:
+		return names[0], nil
+	}
+
+	chosen, err := pick(names, hostPreview(ctx, records, config), false)
+	if err == errNoTerminal {
+		return "", fmt.Errorf("%d hosts match %s: %s", len(names), partial, strings.Join(names, " "))
+	}
+	if err != nil {
+		return "", err
+	}
+	return chosen[0], nil
+}
+
+// sshPort returns the port ssh connects to: from -p or -o Port in args, or from the ssh
+// configuration of dest, or 22.
+func sshPort(sshPath, dest string, args []string) int {
+	port, options := portArg(args)
+	if p, err := strconv.Atoi(port); err == nil {
+		return p
+	}
+
+	// ssh -G prints the configuration it would use, with Port from ~/.ssh/config.
+	out, err := exec.Command(sshPath, append(append([]string{"-G"}, options...), dest)...).Output()
+	if err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			f := strings.Fields(scanner.Text())
+			if len(f) == 2 && f[0] == "port" {
+				if p, err := strconv.Atoi(f[1]); err == nil {
+					return p
+				}
+			}
+		}
+	}
+	return 22
+}
+
+// portArg returns the port given with -p or -o Port in the ssh arguments args, the first one wins
+// as in ssh, and the options before the remote command.
+func portArg(args []string) (string, []string) {
+	options := []string{}
+	port := ""
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			// The remote command.
+			break
+		}
+		options = append(options, a)
+		if a == "--" {
+			break
+		}
+		// Options may be grouped, e.g. -vp 2222 or -p2222.
+		for j := 1; j < len(a); j++ {
+			if !strings.ContainsRune(sshArgOptions, rune(a[j])) {
+				continue
+			}
+			value := a[j+1:]
+			if value == "" && i+1 < len(args) {
+				i++
+				value = args[i]
+				options = append(options, value)
+			}
+			switch a[j] {
+			case 'p':
+				if port == "" {
+					port = value
+				}
+			case 'o':
+				if v, ok := portOption(value); ok && port == "" {
+					port = v
+				}
+			}
+			break
+		}
+	}
+	return port, options
+}
+
+// portOption returns the port of an -o option of ssh, Port=2222 or Port 2222.
+func portOption(opt string) (string, bool) {
+	f := strings.FieldsFunc(opt, func(r rune) bool { return r == '=' || r == ' ' || r == '\t' })
+	if len(f) == 2 && strings.EqualFold(f[0], "port") {
+		return f[1], true
+	}
+	return "", false
+}
+
+// waitForPort waits at most timeout until host accepts connections on port, and prints progress on stderr.
+func waitForPort(ctx context.Context, host string, port int, timeout time.Duration) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	start := time.Now()
+	dialer := net.Dialer{Timeout: 3 * time.Second}
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		waited := time.Since(start)
+		if waited >= timeout {
+			return fmt.Errorf("port %d at %s did not open in %s", port, host, timeout)
+		}
+		log.Printf("Port %d at %s not open yet, waited %s of %s", port, host, waited.Truncate(time.Second), timeout)
+		sleep := waitInterval
+		if timeout-waited < sleep {
+			sleep = timeout - waited
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+	}
+}
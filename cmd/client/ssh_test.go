@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestPortArg(t *testing.T) {
+	tests := []struct {
+		args    []string
+		port    string
+		options []string
+	}{
+		{nil, "", []string{}},
+		{[]string{"-p", "2222"}, "2222", []string{"-p", "2222"}},
+		{[]string{"-p2222"}, "2222", []string{"-p2222"}},
+		{[]string{"-vp", "2222"}, "2222", []string{"-vp", "2222"}},
+		{[]string{"-vp2222"}, "2222", []string{"-vp2222"}},
+		{[]string{"-o", "Port=2200"}, "2200", []string{"-o", "Port=2200"}},
+		{[]string{"-oPort 2201"}, "2201", []string{"-oPort 2201"}},
+		{[]string{"-o", "User=root"}, "", []string{"-o", "User=root"}},
+		{[]string{"-p", "22", "-o", "port=2222"}, "22", []string{"-p", "22", "-o", "port=2222"}},
+		{[]string{"-o", "Port=2200", "-p", "22"}, "2200", []string{"-o", "Port=2200", "-p", "22"}},
+		{[]string{"-p", "23", "-p", "24"}, "23", []string{"-p", "23", "-p", "24"}},
+		{[]string{"-L", "8080:localhost:80", "-p", "23", "ls", "-p", "24"}, "23", []string{"-L", "8080:localhost:80", "-p", "23"}},
+		{[]string{"-4", "-A", "-p", "24"}, "24", []string{"-4", "-A", "-p", "24"}},
+		{[]string{"--", "-p", "2222"}, "", []string{"--"}},
+		{[]string{"-", "-p", "2222"}, "", []string{}},
+		{[]string{"-p"}, "", []string{"-p"}},
+	}
+	for _, tc := range tests {
+		port, options := portArg(tc.args)
+		if port != tc.port || !reflect.DeepEqual(options, tc.options) {
+			t.Errorf("portArg(%q) = %q, %q, want %q, %q", tc.args, port, options, tc.port, tc.options)
+		}
+	}
+}
+
+func TestPortOption(t *testing.T) {
+	tests := []struct {
+		opt  string
+		port string
+		ok   bool
+	}{
+		{"Port=2222", "2222", true},
+		{"port 2222", "2222", true},
+		{"PORT\t22", "22", true},
+		{"Port", "", false},
+		{"User=root", "", false},
+		{"Port=22=23", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		port, ok := portOption(tc.opt)
+		if port != tc.port || ok != tc.ok {
+			t.Errorf("portOption(%q) = %q, %v, want %q, %v", tc.opt, port, ok, tc.port, tc.ok)
+		}
+	}
+}
+
+func TestSSHPort(t *testing.T) {
+	missing := filepath.Join(os.TempDir(), "no-such-directory", "ssh")
+	if got := sshPort(missing, "host", []string{"-p", "2222", "ls"}); got != 2222 {
+		t.Errorf("with -p: got %d, want 2222", got)
+	}
+	if got := sshPort(missing, "host", []string{"-p", "ssh"}); got != 22 {
+		t.Errorf("with a port that is not a number and no ssh: got %d, want 22", got)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ssh is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "ssh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fake := filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\n[ \"$1\" = -G ] && [ \"$2\" = -v ] && [ \"$3\" = user@host ] || exit 1\necho 'user root'\necho 'port 2022'\n"
+	if err := ioutil.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := sshPort(fake, "user@host", []string{"-v", "uptime"}); got != 2022 {
+		t.Errorf("from ssh -G: got %d, want 2022", got)
+	}
+	if got := sshPort(fake, "other", nil); got != 22 {
+		t.Errorf("when ssh -G fails: got %d, want 22", got)
+	}
+}
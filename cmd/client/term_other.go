@@ -0,0 +1,33 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// terminalState is not used where terminals are not supported.
+type terminalState struct{}
+
+var errNoTerminalSupport = errors.New("terminals are not supported on this system")
+
+// isTerminal is always false, so the picker is never used.
+func isTerminal(f *os.File) bool {
+	return false
+}
+
+func makeRaw(f *os.File) (*terminalState, error) {
+	return nil, errNoTerminalSupport
+}
+
+func restoreTerminal(f *os.File, state *terminalState) error {
+	return errNoTerminalSupport
+}
+
+func terminalSize(f *os.File) (int, int, error) {
+	return 0, 0, errNoTerminalSupport
+}
+
+func notifyResize(c chan<- os.Signal) {}
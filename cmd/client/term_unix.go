@@ -0,0 +1,83 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalState is the settings of a terminal, to restore after makeRaw.
+type terminalState unix.Termios
+
+// fileControl runs fn with the descriptor of f, without making f blocking as Fd does.
+func fileControl(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := rc.Control(func(fd uintptr) { opErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return opErr
+}
+
+// isTerminal returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	return fileControl(f, func(fd int) error {
+		_, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+		return err
+	}) == nil
+}
+
+// makeRaw puts the terminal f in raw mode, where every key is read as it is pressed and not echoed,
+// and returns the settings before.
+func makeRaw(f *os.File) (*terminalState, error) {
+	var old *terminalState
+	err := fileControl(f, func(fd int) error {
+		t, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+		if err != nil {
+			return err
+		}
+		old = (*terminalState)(t)
+		raw := *t
+		raw.Iflag &^= unix.BRKINT | unix.ICRNL | unix.INPCK | unix.ISTRIP | unix.IXON
+		raw.Lflag &^= unix.ECHO | unix.ICANON | unix.ISIG | unix.IEXTEN
+		raw.Cflag |= unix.CS8
+		raw.Cc[unix.VMIN] = 1
+		raw.Cc[unix.VTIME] = 0
+		return unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw)
+	})
+	return old, err
+}
+
+// restoreTerminal sets the terminal f back to state.
+func restoreTerminal(f *os.File, state *terminalState) error {
+	return fileControl(f, func(fd int) error {
+		return unix.IoctlSetTermios(fd, ioctlWriteTermios, (*unix.Termios)(state))
+	})
+}
+
+// terminalSize returns the columns and rows of the terminal f.
+func terminalSize(f *os.File) (int, int, error) {
+	var ws *unix.Winsize
+	err := fileControl(f, func(fd int) error {
+		var err error
+		ws, err = unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// notifyResize sends to c when the size of the terminal is changed.
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
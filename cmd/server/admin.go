@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+var disabledZones = zoneSwitch{zones: map[string]bool{}}
+
+// zoneSwitch is the zones that are disabled with the admin API. Disabled zones are not transferred
+// and not in the cache until they are enabled again.
+type zoneSwitch struct {
+	sync.RWMutex
+	zones map[string]bool
+}
+
+func (s *zoneSwitch) disabled(zone string) bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.zones[zone]
+}
+
+func (s *zoneSwitch) set(zone string, disabled bool) {
+	s.Lock()
+	defer s.Unlock()
+	if disabled {
+		s.zones[zone] = true
+	} else {
+		delete(s.zones, zone)
+	}
+}
+
+// enabled returns the zones in zones that are not disabled.
+func (s *zoneSwitch) enabled(zones []string) []string {
+	s.RLock()
+	defer s.RUnlock()
+	ret := []string{}
+	for _, z := range zones {
+		if !s.zones[z] {
+			ret = append(ret, z)
+		}
+	}
+	return ret
+}
+
+// retain forgets disabled zones that are not in zones.
+func (s *zoneSwitch) retain(zones []string) {
+	keep := map[string]bool{}
+	for _, z := range zones {
+		keep[z] = true
+	}
+	s.Lock()
+	defer s.Unlock()
+	for z := range s.zones {
+		if !keep[z] {
+			delete(s.zones, z)
+		}
+	}
+}
+
+// zoneInfo is what the admin API tells about one zone.
+type zoneInfo struct {
+	Zone        string           `json:"zone"`
+	Disabled    bool             `json:"disabled"`
+	NS          string           `json:"ns"`
+	Serial      uint32           `json:"serial"`
+	Records     int              `json:"records"`
+	LoadedAt    *time.Time       `json:"loaded_at,omitempty"`
+	LastAttempt *time.Time       `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time       `json:"last_success,omitempty"`
+	LastError   string           `json:"last_error"`
+	Transfers   uint64           `json:"transfers"`
+	Errors      uint64           `json:"errors"`
+	History     []transferRecord `json:"history"`
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// zoneInfoFor returns what is known about zone in the cache and from its transfers.
+func zoneInfoFor(config *gethost.Config, zone string) zoneInfo {
+	ts := metrics.transferStatsFor(zone)
+	info := zoneInfo{
+		Zone:        zone,
+		Disabled:    disabledZones.disabled(zone),
+		NS:          ts.NS,
+		LastAttempt: timeOrNil(ts.LastAttempt),
+		LastSuccess: timeOrNil(ts.LastSuccess),
+		LastError:   ts.LastError,
+		Transfers:   ts.Transfers,
+		Errors:      ts.Errors,
+		History:     ts.History,
+	}
+	if info.NS == "" {
+		info.NS = config.NS
+	}
+	if info.History == nil {
+		info.History = []transferRecord{}
+	}
+	dnsRR.RLock()
+	if d, ok := dnsRR.zones[zone]; ok {
+		info.Records = len(d.RR)
+		if d.SOA != nil {
+			info.Serial = d.SOA.Serial
+		}
+		info.LoadedAt = timeOrNil(dnsRR.zoneAge[zone])
+	}
+	dnsRR.RUnlock()
+	return info
+}
+
+// adminZone returns the configured zone in the request, or writes 404 Not Found and returns "".
+func adminZone(w http.ResponseWriter, r *http.Request, config *gethost.Config) string {
+	zone := configuredZone(config, mux.Vars(r)["zone"])
+	if zone == "" {
+		writeError(w, "unknown zone "+mux.Vars(r)["zone"], http.StatusNotFound)
+	}
+	return zone
+}
+
+// adminName is the token name of the request, for logs.
+func adminName(r *http.Request) string {
+	if s := scopeFrom(r); s != nil && s.name != "" {
+		return s.name
+	}
+	return "unnamed token"
+}
+
+// httpAdminZones lists all configured zones.
+func httpAdminZones(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	ret := []zoneInfo{}
+	for _, z := range config.Zones {
+		ret = append(ret, zoneInfoFor(config, z))
+	}
+	writeJSON(w, ret)
+}
+
+// httpAdminZone shows one zone.
+func httpAdminZone(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	zone := adminZone(w, r, config)
+	if zone == "" {
+		return
+	}
+	writeJSON(w, zoneInfoFor(config, zone))
+}
+
+// httpAdminRefresh transfers one zone now, regardless of MinRefreshInterval.
+func httpAdminRefresh(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpAdminRefresh", ext.RPCServerOption(spanCtx))
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	defer span.Finish()
+
+	zone := adminZone(w, r, config)
+	if zone == "" {
+		return
+	}
+	if disabledZones.disabled(zone) {
+		writeError(w, "zone "+zone+" is disabled", http.StatusConflict)
+		return
+	}
+	log.Printf("Admin %s refreshes zone %s\n", adminName(r), zone)
+	outcome, _, err := refreshes.force(ctx, config, []string{zone}, 0)
+	w.Header().Set("X-Cache-Refresh", outcome)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, zoneInfoFor(config, zone))
+}
+
+// httpAdminDisable stops transfers of one zone and removes it from the cache. A running update
+// finishes first, so it does not add the zone again.
+func httpAdminDisable(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	zone := adminZone(w, r, config)
+	if zone == "" {
+		return
+	}
+	log.Printf("Admin %s disables zone %s\n", adminName(r), zone)
+	refreshes.locked(func() {
+		disabledZones.set(zone, true)
+		watchers.publish(recordChanges(config, dnsRR.dropZone(zone))...)
+	})
+	writeJSON(w, zoneInfoFor(config, zone))
+}
+
+// httpAdminEnable enables one zone again and transfers it.
+func httpAdminEnable(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpAdminEnable", ext.RPCServerOption(spanCtx))
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	defer span.Finish()
+
+	zone := adminZone(w, r, config)
+	if zone == "" {
+		return
+	}
+	log.Printf("Admin %s enables zone %s\n", adminName(r), zone)
+	disabledZones.set(zone, false)
+	outcome, _, err := refreshes.force(ctx, config, []string{zone}, 0)
+	w.Header().Set("X-Cache-Refresh", outcome)
+	if err != nil {
+		writeError(w, "zone is enabled but the transfer failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, zoneInfoFor(config, zone))
+}
+
+// httpAdminDrop removes the cached data of one zone, it is loaded again at the next update.
+func httpAdminDrop(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	zone := adminZone(w, r, config)
+	if zone == "" {
+		return
+	}
+	log.Printf("Admin %s drops cached data of zone %s\n", adminName(r), zone)
+	refreshes.locked(func() {
+		watchers.publish(recordChanges(config, dnsRR.dropZone(zone))...)
+	})
+	writeJSON(w, zoneInfoFor(config, zone))
+}
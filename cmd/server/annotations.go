@@ -0,0 +1,424 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+var annotations = annotationStore{tags: map[string]map[string]string{}}
+
+// annotationStore holds user supplied tags for names or name patterns, that is not in DNS.
+// Patterns use the syntax of path.Match, e.g. "*.dc2.example.tld".
+type annotationStore struct {
+	sync.RWMutex
+	file       string                       // file is where the store is persisted, empty is only in memory.
+	tags       map[string]map[string]string // tags is tag name to value, per name or pattern.
+	generation uint64                       // generation is incremented on every change, see cacheETag.
+}
+
+// load reads the store from its file. A missing file is an empty store.
+func (s *annotationStore) load() error {
+	if s.file == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tags := map[string]map[string]string{}
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return fmt.Errorf("%s: %s", s.file, err)
+	}
+	s.Lock()
+	s.tags = tags
+	s.generation++
+	s.Unlock()
+	return nil
+}
+
+// switchFile loads the store from file instead, and keeps the old store if file can not be loaded.
+func (s *annotationStore) switchFile(file string) error {
+	n := annotationStore{file: file, tags: map[string]map[string]string{}}
+	if err := n.load(); err != nil {
+		return err
+	}
+	s.Lock()
+	s.file = n.file
+	s.tags = n.tags
+	s.generation++
+	s.Unlock()
+	return nil
+}
+
+// save writes tags to the file of the store, the caller must hold the lock.
+func (s *annotationStore) save(tags map[string]map[string]string) error {
+	if s.file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.file), filepath.Base(s.file))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// all returns a copy of all patterns and their tags.
+func (s *annotationStore) all() map[string]map[string]string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.copyAll()
+}
+
+// copyAll returns a copy of all patterns and their tags, the caller must hold the lock.
+func (s *annotationStore) copyAll() map[string]map[string]string {
+	ret := map[string]map[string]string{}
+	for p, tags := range s.tags {
+		ret[p] = copyTags(tags)
+	}
+	return ret
+}
+
+// get returns the tags set on exactly pattern.
+func (s *annotationStore) get(pattern string) (map[string]string, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	tags, ok := s.tags[pattern]
+	return copyTags(tags), ok
+}
+
+// set adds tags to pattern, or with replace set replaces all tags of pattern.
+// Many patterns are set at once and persisted together, and nothing is changed if they can not be persisted.
+func (s *annotationStore) set(tags map[string]map[string]string, replace bool) error {
+	for p := range tags {
+		if err := validPattern(p); err != nil {
+			return err
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	n := s.copyAll()
+	for p, t := range tags {
+		if replace || n[p] == nil {
+			n[p] = map[string]string{}
+		}
+		for k, v := range t {
+			n[p][k] = v
+		}
+	}
+	if err := s.save(n); err != nil {
+		return err
+	}
+	s.tags = n
+	s.generation++
+	return nil
+}
+
+// delete removes tag from pattern, or all tags of pattern if tag is empty.
+// It returns false if there was nothing to delete, and nothing is deleted if it can not be persisted.
+func (s *annotationStore) delete(pattern string, tag string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	tags, ok := s.tags[pattern]
+	if !ok {
+		return false, nil
+	}
+	if _, ok := tags[tag]; tag != "" && !ok {
+		return false, nil
+	}
+	n := s.copyAll()
+	if tag == "" {
+		delete(n, pattern)
+	} else {
+		delete(n[pattern], tag)
+		if len(n[pattern]) == 0 {
+			delete(n, pattern)
+		}
+	}
+	if err := s.save(n); err != nil {
+		return true, err
+	}
+	s.tags = n
+	s.generation++
+	return true, nil
+}
+
+// gen returns the generation of the store.
+func (s *annotationStore) gen() uint64 {
+	s.RLock()
+	defer s.RUnlock()
+	return s.generation
+}
+
+// tagsFor returns the tags that apply to name. Tags from patterns are applied in sorted order,
+// and tags set on the name itself are applied last.
+func (s *annotationStore) tagsFor(name string) map[string]string {
+	s.RLock()
+	defer s.RUnlock()
+	patterns := []string{}
+	for p := range s.tags {
+		if p == name {
+			continue
+		}
+		if ok, _ := path.Match(p, name); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	sort.Strings(patterns)
+	if _, ok := s.tags[name]; ok {
+		patterns = append(patterns, name)
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	ret := map[string]string{}
+	for _, p := range patterns {
+		for k, v := range s.tags[p] {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
+// filter returns the names whose tags match all filters, see tagFilter.
+func (s *annotationStore) filter(names []string, filters []tagFilter) []string {
+	if len(filters) == 0 {
+		return names
+	}
+	ret := []string{}
+	for _, name := range names {
+		tags := s.tagsFor(name)
+		ok := true
+		for _, f := range filters {
+			if !f.match(tags) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// annotate sets the tags of every detail.
+func (s *annotationStore) annotate(details []hostDetail) {
+	for i := range details {
+		details[i].Tags = s.tagsFor(details[i].Name)
+	}
+}
+
+// tagFilter is "tag" to match names that have tag, or "tag=value" to match names where tag has value.
+type tagFilter struct {
+	tag      string
+	value    string
+	hasValue bool
+}
+
+func parseTagFilters(params []string) []tagFilter {
+	var filters []tagFilter
+	for _, p := range params {
+		f := tagFilter{tag: p}
+		if i := strings.Index(p, "="); i >= 0 {
+			f = tagFilter{tag: p[:i], value: p[i+1:], hasValue: true}
+		}
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+func (f tagFilter) match(tags map[string]string) bool {
+	v, ok := tags[f.tag]
+	if !ok {
+		return false
+	}
+	return !f.hasValue || v == f.value
+}
+
+// patternError is returned for names or patterns that can not be used.
+type patternError string
+
+func (e patternError) Error() string {
+	return string(e)
+}
+
+func validPattern(pattern string) error {
+	if pattern == "" {
+		return patternError("empty name or pattern")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return patternError(fmt.Sprintf("bad pattern %q: %s", pattern, err))
+	}
+	return nil
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	ret := map[string]string{}
+	for k, v := range tags {
+		ret[k] = v
+	}
+	return ret
+}
+
+// readCSV reads rows of "pattern,tag,value". An optional header row starting with "pattern" is skipped.
+func readCSV(r io.Reader) (map[string]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	ret := map[string]map[string]string{}
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.ToLower(row[0]) == "pattern" {
+			continue
+		}
+		if row[1] == "" {
+			return nil, fmt.Errorf("line %d: empty tag", line)
+		}
+		if ret[row[0]] == nil {
+			ret[row[0]] = map[string]string{}
+		}
+		ret[row[0]][row[1]] = row[2]
+	}
+	return ret, nil
+}
+
+func httpAnnotations(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpAnnotations", ext.RPCServerOption(spanCtx))
+	defer span.Finish()
+
+	vars := mux.Vars(r)
+	pattern := vars["pattern"]
+	tag := vars["tag"]
+
+	switch r.Method {
+	case "GET":
+		if pattern == "" {
+			writeJSON(w, annotations.all())
+			return
+		}
+		tags, ok := annotations.get(pattern)
+		if !ok {
+			writeError(w, "No annotations for "+pattern, http.StatusNotFound)
+			return
+		}
+		if tag != "" {
+			v, ok := tags[tag]
+			if !ok {
+				writeError(w, "No tag "+tag+" for "+pattern, http.StatusNotFound)
+				return
+			}
+			writeJSON(w, v)
+			return
+		}
+		writeJSON(w, tags)
+
+	case "PUT", "POST":
+		// PUT replaces all tags of the pattern, POST adds to them.
+		var tags map[string]string
+		if tag != "" {
+			var v string
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				writeError(w, "Body must be a JSON string: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			tags = map[string]string{tag: v}
+		} else if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
+			writeError(w, "Body must be a JSON object of strings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		replace := r.Method == "PUT" && tag == ""
+		if err := annotations.set(map[string]map[string]string{pattern: tags}, replace); err != nil {
+			annotationError(w, err)
+			return
+		}
+		tags, _ = annotations.get(pattern)
+		writeJSON(w, tags)
+
+	case "DELETE":
+		ok, err := annotations.delete(pattern, tag)
+		if err != nil {
+			annotationError(w, err)
+			return
+		}
+		if !ok {
+			writeError(w, "No annotations for "+pattern, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func httpAnnotationsImport(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpAnnotationsImport", ext.RPCServerOption(spanCtx))
+	defer span.Finish()
+
+	tags, err := readCSV(r.Body)
+	if err != nil {
+		writeError(w, "Could not read CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := annotations.set(tags, false); err != nil {
+		annotationError(w, err)
+		return
+	}
+	if config.Verbose == true {
+		log.Printf("Imported annotations for %d names or patterns\n", len(tags))
+	}
+	writeJSON(w, struct {
+		Imported int `json:"imported"`
+	}{len(tags)})
+}
+
+// annotationError is bad request for invalid patterns, and internal server error if the store could not be saved.
+func annotationError(w http.ResponseWriter, err error) {
+	if _, ok := err.(patternError); ok {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Println("Could not save annotations:", err)
+	writeError(w, err.Error(), http.StatusInternalServerError)
+}
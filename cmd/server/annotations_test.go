@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnnotationsSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "annotations.json")
+
+	s := annotationStore{file: file, tags: map[string]map[string]string{}}
+	if err := s.set(map[string]map[string]string{"web-*": {"team": "web", "env": "prod"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.delete("web-*", "env"); !ok || err != nil {
+		t.Fatalf("delete: got %v, %v", ok, err)
+	}
+
+	loaded := annotationStore{file: file}
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{"web-*": {"team": "web"}}
+	if got := loaded.all(); !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %v, want %v", got, want)
+	}
+}
+
+// TestAnnotationsFailedSave checks that a change that can not be saved is not kept in memory.
+func TestAnnotationsFailedSave(t *testing.T) {
+	s := annotationStore{
+		file: filepath.Join(os.TempDir(), "no-such-directory", "annotations.json"),
+		tags: map[string]map[string]string{"web-*": {"team": "web"}},
+	}
+
+	if err := s.set(map[string]map[string]string{"web-*": {"team": "db"}, "db-*": {"team": "db"}}, false); err == nil {
+		t.Error("set did not fail")
+	}
+	if err := s.set(map[string]map[string]string{"web-*": {"env": "prod"}}, true); err == nil {
+		t.Error("set with replace did not fail")
+	}
+	if ok, err := s.delete("web-*", "team"); !ok || err == nil {
+		t.Errorf("delete of a tag: got %v, %v, want true and an error", ok, err)
+	}
+	if ok, err := s.delete("web-*", ""); !ok || err == nil {
+		t.Errorf("delete of a pattern: got %v, %v, want true and an error", ok, err)
+	}
+
+	want := map[string]map[string]string{"web-*": {"team": "web"}}
+	if got := s.all(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after failed saves, want %v", got, want)
+	}
+	if s.gen() != 0 {
+		t.Errorf("generation is %d after failed saves, want 0", s.gen())
+	}
+}
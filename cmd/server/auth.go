@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+type contextKey int
+
+const scopeKey contextKey = iota
+
+// publicRoutes can be used without token, so load balancers can check the server
+// and browsers can load the web UI.
+var publicRoutes = map[string]bool{
+	"/":             true,
+	"/ui/":          true,
+	"/healthz":      true,
+	"/readyz":       true,
+	"/version":      true,
+	"/openapi.json": true,
+}
+
+// scope is what one token may see. A nil scope, or one without zones and patterns, sees everything.
+type scope struct {
+	name     string
+	zones    map[string]bool
+	patterns []string
+	admin    bool // admin may use the admin API.
+}
+
+// all returns true if the scope sees everything.
+func (s *scope) all() bool {
+	return s == nil || (len(s.zones) == 0 && len(s.patterns) == 0)
+}
+
+// zone returns true if all names in zone are visible.
+func (s *scope) zone(zone string) bool {
+	return s.all() || s.zones[dns.Fqdn(strings.ToLower(zone))]
+}
+
+// visible returns true if name in zone is visible.
+func (s *scope) visible(zone string, name string) bool {
+	if s.zone(zone) {
+		return true
+	}
+	for _, p := range s.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func newScope(t gethost.TokenConfig) *scope {
+	s := &scope{name: t.Name, zones: map[string]bool{}, patterns: t.Patterns, admin: t.Admin}
+	for _, z := range t.Zones {
+		s.zones[dns.Fqdn(strings.ToLower(z))] = true
+	}
+	return s
+}
+
+// hashToken returns the hash of token as it is written in the configuration.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate returns the scope of the bearer token in the Authorization header auth, and false if
+// no configured token matches.
+func authenticate(auth string, tokens []gethost.TokenConfig) (*scope, bool) {
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, false
+	}
+	hash := []byte(hashToken(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))))
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(t.Hash))) == 1 {
+			return newScope(t), true
+		}
+	}
+	return nil, false
+}
+
+// authMiddleware requires a valid bearer token for all but publicRoutes when tokens are configured,
+// and puts the scope of the token in the request context.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := liveConfig.get().Tokens
+		if len(tokens) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if t, err := cr.GetPathTemplate(); err == nil && publicRoutes[t] {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		s, ok := authenticate(r.Header.Get("Authorization"), tokens)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gethost"`)
+			writeError(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey, s)))
+	})
+}
+
+// scopeFrom returns the scope of the request, nil sees everything.
+func scopeFrom(r *http.Request) *scope {
+	return scopeOf(r.Context())
+}
+
+// scopeOf returns the scope in ctx, nil sees everything.
+func scopeOf(ctx context.Context) *scope {
+	s, _ := ctx.Value(scopeKey).(*scope)
+	return s
+}
+
+// requireAll answers 403 Forbidden instead of calling handler if the token does not see everything.
+func requireAll(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !scopeFrom(r).all() {
+			writeError(w, "token is restricted to some zones or names", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// requireAdmin answers 403 Forbidden instead of calling handler if the token is not an admin token.
+// The admin API is not available without tokens in the configuration.
+func requireAdmin(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(liveConfig.get().Tokens) == 0 {
+			writeError(w, "the admin API requires tokens in the configuration", http.StatusForbidden)
+			return
+		}
+		if s := scopeFrom(r); s == nil || !s.admin {
+			writeError(w, "token is not an admin token", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// TestScopedToken checks that a token restricted to a zone, written without trailing dot, sees that zone and only it.
+func TestScopedToken(t *testing.T) {
+	handler := setupAPI(t)
+	config := *liveConfig.get()
+	config.Tokens = append(append([]gethost.TokenConfig(nil), config.Tokens...),
+		gethost.TokenConfig{Name: "other", Hash: hashToken("other-token"), Zones: []string{"Other.tld"}})
+	liveConfig.set(&config, time.Now())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer other-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/v2/hosts?q=")
+	resp := struct {
+		Results []string `json:"results"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("/v2/hosts: %s: %s", err, rec.Body.String())
+	}
+	if want := []string{"box-1.other.tld"}; !reflect.DeepEqual(resp.Results, want) {
+		t.Errorf("/v2/hosts: got %v, want %v", resp.Results, want)
+	}
+
+	if rec := get("/hosts/box-1.other.tld/records"); rec.Code != http.StatusOK {
+		t.Errorf("records in the zone of the token: got %d, want 200", rec.Code)
+	}
+	if rec := get("/hosts/web-1.example.tld/records"); rec.Code != http.StatusNotFound {
+		t.Errorf("records in another zone: got %d, want 404", rec.Code)
+	}
+	if rec := get("/status"); !strings.Contains(rec.Body.String(), "other.tld.") || strings.Contains(rec.Body.String(), "example.tld.") {
+		t.Errorf("/status: got %s, want only other.tld.", rec.Body.String())
+	}
+	if rec := get("/hosts/box/nc?zone=example.tld"); rec.Code != http.StatusForbidden {
+		t.Errorf("refresh of another zone: got %d, want 403", rec.Code)
+	}
+
+	s := newScope(config.Tokens[len(config.Tokens)-1])
+	if !s.zone("other.tld.") || !s.zone("OTHER.TLD") || s.zone("example.tld.") {
+		t.Errorf("scope of zone Other.tld: got other.tld. %v, OTHER.TLD %v, example.tld. %v",
+			s.zone("other.tld."), s.zone("OTHER.TLD"), s.zone("example.tld."))
+	}
+}
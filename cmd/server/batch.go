@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+const (
+	maxBatch     = 1000    // maxBatch is the largest number of queries in one batch.
+	maxBatchBody = 1 << 20 // maxBatchBody is the largest request body of a batch, in bytes.
+)
+
+// batchRequest is the body of /v2/hosts/batch.
+type batchRequest struct {
+	Queries []batchQuery `json:"queries"`
+}
+
+// batchQuery is one search in a batch, with the options of /v2/hosts.
+type batchQuery struct {
+	Q      string   `json:"q"`
+	Mode   string   `json:"mode,omitempty"`
+	Zones  []string `json:"zone,omitempty"`
+	Types  []string `json:"type,omitempty"`
+	Tags   []string `json:"tag,omitempty"`
+	Limit  int      `json:"limit,omitempty"` // Limit is the number of results, defaultLimit if not given.
+	Detail bool     `json:"detail,omitempty"`
+}
+
+// batchResult is the result of one query. A query that can not be run has Error, the others are still run.
+type batchResult struct {
+	Q       string      `json:"q"`
+	Results interface{} `json:"results"`
+	Total   int         `json:"total"` // Total is the number of matching names, more than in Results if Limit was reached.
+	Error   string      `json:"error,omitempty"`
+}
+
+// batchResponse is the results in the order of the queries, and the cache they were run against.
+type batchResponse struct {
+	Results    []batchResult `json:"results"`
+	CacheAge   int           `json:"cache_age"`  // CacheAge is the age of the cache in seconds.
+	Generation uint64        `json:"generation"` // Generation is the generation of the cache, see cache.Generation.
+}
+
+// batchTries is how many times a batch is run with the lock taken for each query, before it is run
+// with the lock held for the whole batch.
+const batchTries = 3
+
+// batch runs all queries in sc against one generation of the cache. The lock is taken for each query,
+// so a large batch does not hold back updates, and the batch is run again if the cache changed.
+func (c *cache) batch(queries []batchQuery, sc *scope) batchResponse {
+	for try := 1; ; try++ {
+		c.RLock()
+		ret := batchResponse{
+			Results:    make([]batchResult, 0, len(queries)),
+			CacheAge:   int(time.Since(c.age).Seconds()),
+			Generation: c.generation,
+		}
+		if try == batchTries {
+			for _, bq := range queries {
+				ret.Results = append(ret.Results, c.batchOne(bq, sc))
+			}
+			c.RUnlock()
+			return ret
+		}
+		c.RUnlock()
+
+		for _, bq := range queries {
+			c.RLock()
+			ret.Results = append(ret.Results, c.batchOne(bq, sc))
+			c.RUnlock()
+		}
+		c.RLock()
+		same := c.generation == ret.Generation
+		c.RUnlock()
+		if same {
+			return ret
+		}
+	}
+}
+
+// batchOne runs one query of a batch, the caller must hold the lock.
+func (c *cache) batchOne(bq batchQuery, sc *scope) batchResult {
+	res := batchResult{Q: bq.Q, Results: []string{}}
+	limit := bq.Limit
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit < 1 || limit > maxLimit {
+		res.Error = "limit must be between 1 and " + strconv.Itoa(maxLimit)
+		return res
+	}
+	q := hostQuery{
+		Query: bq.Q,
+		Mode:  bq.Mode,
+		Zones: bq.Zones,
+		Types: bq.Types,
+		Tags:  parseTagFilters(bq.Tags),
+		Scope: sc,
+	}
+	hostnames, err := c.match(q)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	hostnames = annotations.filter(hostnames, q.Tags)
+	res.Total = len(hostnames)
+	if len(hostnames) > limit {
+		hostnames = hostnames[:limit]
+	}
+	if !bq.Detail {
+		res.Results = hostnames
+		return res
+	}
+	details := []hostDetail{}
+	for _, name := range hostnames {
+		if d, ok := c.detail(name); ok {
+			details = append(details, d)
+		}
+	}
+	annotations.annotate(details)
+	res.Results = details
+	return res
+}
+
+// httpHostsBatch runs many searches in one request, see batchRequest. The body is JSON, and so is the response.
+func httpHostsBatch(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpHostsBatch", ext.RPCServerOption(spanCtx))
+	defer span.Finish()
+
+	req := batchRequest{}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, "Body must be a JSON object with queries: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Queries) == 0 {
+		writeError(w, "No queries", http.StatusBadRequest)
+		return
+	}
+	if len(req.Queries) > maxBatch {
+		writeError(w, "At most "+strconv.Itoa(maxBatch)+" queries in a batch", http.StatusBadRequest)
+		return
+	}
+
+	// The rate limit took one token for the request, the other queries are charged here.
+	limiter.charge(clientKey(r), limitSearch, len(req.Queries)-1, config, time.Now())
+	ret := dnsRR.batch(req.Queries, scopeFrom(r))
+	atomic.AddInt64(&dnsRR.APIhits, int64(len(req.Queries)))
+
+	if config.Verbose == true {
+		log.Printf("Send results of %d queries in a batch\n", len(req.Queries))
+	}
+	writeJSON(w, ret)
+}
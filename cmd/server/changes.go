@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+var changes changeLog
+
+// changeSet is the difference for one zone between two updates of the cache.
+type changeSet struct {
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Zone      string    `json:"zone"`
+	OldSerial uint32    `json:"old_serial"`
+	Serial    uint32    `json:"serial"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+	Changed   []string  `json:"changed"`
+}
+
+// empty returns true if nothing changed.
+func (cs changeSet) empty() bool {
+	return len(cs.Added) == 0 && len(cs.Removed) == 0 && len(cs.Changed) == 0
+}
+
+// changeLog is a bounded in memory log of change sets, oldest first.
+type changeLog struct {
+	sync.RWMutex
+	sets   []changeSet
+	lastID int
+	max    int // max is the number of change sets to keep, 0 is unbounded.
+}
+
+// add gives cs the next ID, appends it to the log and returns it.
+// The oldest change sets are dropped when the log is full.
+func (l *changeLog) add(cs changeSet) changeSet {
+	l.Lock()
+	defer l.Unlock()
+	l.lastID++
+	cs.ID = l.lastID
+	l.sets = append(l.sets, cs)
+	if l.max > 0 && len(l.sets) > l.max {
+		l.sets = append([]changeSet(nil), l.sets[len(l.sets)-l.max:]...)
+	}
+	return cs
+}
+
+// setMax changes the number of change sets to keep, and drops the oldest if there are more.
+func (l *changeLog) setMax(max int) {
+	l.Lock()
+	defer l.Unlock()
+	l.max = max
+	if l.max > 0 && len(l.sets) > l.max {
+		l.sets = append([]changeSet(nil), l.sets[len(l.sets)-l.max:]...)
+	}
+}
+
+// since returns all change sets in the log newer than t.
+func (l *changeLog) since(t time.Time) []changeSet {
+	l.RLock()
+	defer l.RUnlock()
+	ret := []changeSet{}
+	for _, cs := range l.sets {
+		if cs.Time.After(t) {
+			ret = append(ret, cs)
+		}
+	}
+	return ret
+}
+
+// diffZones returns the non empty change sets between old and new.
+// A zone that is only in new has all its names added, and a zone that is only in old has all its names removed.
+func diffZones(old, new map[string]gethost.SOAwithRR, now time.Time) []changeSet {
+	var sets []changeSet
+	for z, n := range new {
+		o := old[z]
+		if o.SOA != nil && o.SOA == n.SOA {
+			// The zone was not transferred again.
+			continue
+		}
+		cs := diffZone(z, o, n)
+		cs.Time = now
+		if !cs.empty() {
+			sets = append(sets, cs)
+		}
+	}
+	for z, o := range old {
+		if _, ok := new[z]; ok {
+			continue
+		}
+		cs := diffZone(z, o, gethost.SOAwithRR{})
+		cs.Time = now
+		if !cs.empty() {
+			sets = append(sets, cs)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Zone < sets[j].Zone })
+	return sets
+}
+
+// diffZone returns the names that were added, removed or got other records between old and new.
+func diffZone(zone string, old, new gethost.SOAwithRR) changeSet {
+	cs := changeSet{
+		Zone:    zone,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	if old.SOA != nil {
+		cs.OldSerial = old.SOA.Serial
+	}
+	if new.SOA != nil {
+		cs.Serial = new.SOA.Serial
+	}
+	for name, rrs := range new.RR {
+		oldRRs, ok := old.RR[name]
+		if !ok {
+			cs.Added = append(cs.Added, name)
+		} else if !equalRRs(oldRRs, rrs) {
+			cs.Changed = append(cs.Changed, name)
+		}
+	}
+	for name := range old.RR {
+		if _, ok := new.RR[name]; !ok {
+			cs.Removed = append(cs.Removed, name)
+		}
+	}
+	sort.Strings(cs.Added)
+	sort.Strings(cs.Removed)
+	sort.Strings(cs.Changed)
+	return cs
+}
+
+// equalRRs compares two sets of records, regardless of order.
+func equalRRs(a, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa := rrStrings(a)
+	sb := rrStrings(b)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	s := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		s = append(s, rr.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+// parseSince parses the since parameter, either as a RFC3339 timestamp,
+// a duration back in time from now such as "12h", or a unix timestamp.
+// An empty string is the beginning of time.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if i, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return time.Unix(i, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("can not parse since %q, use RFC3339, a duration or a unix timestamp", since)
+}
+
+func httpChanges(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpChanges", ext.RPCServerOption(spanCtx))
+	defer span.Finish()
+
+	since, err := parseSince(r.URL.Query().Get("since"), time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, filterChanges(changes.since(since), scopeFrom(r)))
+}
+
+// filterChanges returns the change sets with only the names that sc may see.
+func filterChanges(sets []changeSet, sc *scope) []changeSet {
+	if sc.all() {
+		return sets
+	}
+	visible := func(zone string, names []string) []string {
+		ret := []string{}
+		for _, n := range names {
+			if sc.visible(zone, n) {
+				ret = append(ret, n)
+			}
+		}
+		return ret
+	}
+	ret := []changeSet{}
+	for _, cs := range sets {
+		cs.Added = visible(cs.Zone, cs.Added)
+		cs.Removed = visible(cs.Zone, cs.Removed)
+		cs.Changed = visible(cs.Zone, cs.Changed)
+		if !cs.empty() {
+			ret = append(ret, cs)
+		}
+	}
+	return ret
+}
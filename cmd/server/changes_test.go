@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+func TestEqualRRs(t *testing.T) {
+	rr := func(s string) dns.RR {
+		r, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	a1 := rr("web.example.tld. 300 IN A 10.0.0.1")
+	a2 := rr("web.example.tld. 300 IN A 10.0.0.2")
+	a1ttl := rr("web.example.tld. 600 IN A 10.0.0.1")
+	aaaa := rr("web.example.tld. 300 IN AAAA ::1")
+
+	tests := []struct {
+		a, b []dns.RR
+		want bool
+	}{
+		{nil, nil, true},
+		{[]dns.RR{a1}, []dns.RR{a1}, true},
+		{[]dns.RR{a1, a2}, []dns.RR{a2, a1}, true},
+		{[]dns.RR{a1}, []dns.RR{a2}, false},
+		{[]dns.RR{a1}, []dns.RR{a1ttl}, false},
+		{[]dns.RR{a1}, []dns.RR{a1, aaaa}, false},
+		{[]dns.RR{a1, a1}, []dns.RR{a1, a2}, false},
+		{[]dns.RR{a1}, nil, false},
+	}
+	for _, tc := range tests {
+		if got := equalRRs(tc.a, tc.b); got != tc.want {
+			t.Errorf("equalRRs(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDiffZones(t *testing.T) {
+	now := time.Now()
+	old := map[string]gethost.SOAwithRR{
+		"example.tld.": testZone(t, "example.tld.", 100,
+			"web-1.example.tld. 300 IN A 10.0.0.1",
+			"web-2.example.tld. 300 IN A 10.0.0.2",
+			"db.example.tld. 300 IN A 10.0.0.3"),
+		"other.tld.": testZone(t, "other.tld.", 7,
+			"box.other.tld. 300 IN A 10.1.0.1"),
+		"same.tld.": testZone(t, "same.tld.", 1,
+			"a.same.tld. 300 IN A 10.2.0.1"),
+	}
+	new := map[string]gethost.SOAwithRR{
+		"example.tld.": testZone(t, "example.tld.", 101,
+			"web-1.example.tld. 300 IN A 10.0.0.1",
+			"web-2.example.tld. 300 IN A 10.0.0.20",
+			"web-3.example.tld. 300 IN A 10.0.0.4"),
+		"same.tld.": testZone(t, "same.tld.", 2,
+			"a.same.tld. 300 IN A 10.2.0.1"),
+		"third.tld.": testZone(t, "third.tld.", 3,
+			"x.third.tld. 300 IN A 10.3.0.1"),
+	}
+
+	want := []changeSet{
+		{Time: now, Zone: "example.tld.", OldSerial: 100, Serial: 101,
+			Added: []string{"web-3.example.tld"}, Removed: []string{"db.example.tld"}, Changed: []string{"web-2.example.tld"}},
+		{Time: now, Zone: "other.tld.", OldSerial: 7, Serial: 0,
+			Added: []string{}, Removed: []string{"box.other.tld"}, Changed: []string{}},
+		{Time: now, Zone: "third.tld.", OldSerial: 0, Serial: 3,
+			Added: []string{"x.third.tld"}, Removed: []string{}, Changed: []string{}},
+	}
+	if got := diffZones(old, new, now); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffZones:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// A zone that was not transferred again is the same, and is not compared.
+	kept := map[string]gethost.SOAwithRR{"example.tld.": old["example.tld."], "other.tld.": old["other.tld."], "same.tld.": old["same.tld."]}
+	if got := diffZones(old, kept, now); len(got) != 0 {
+		t.Errorf("diffZones of the same zones: got %+v, want none", got)
+	}
+}
+
+func TestRetainAndDropZones(t *testing.T) {
+	c := &cache{
+		zones: map[string]gethost.SOAwithRR{
+			"example.tld.": testZone(t, "example.tld.", 1, "web.example.tld. 300 IN A 10.0.0.1"),
+			"other.tld.":   testZone(t, "other.tld.", 1, "box.other.tld. 300 IN A 10.1.0.1"),
+		},
+		zoneAge: map[string]time.Time{},
+	}
+	c.data, c.soas = mergeZones(c.zones)
+
+	sets := c.retainZones([]string{"example.tld.", "other.tld."})
+	if len(sets) != 0 || c.generation != 0 {
+		t.Errorf("retainZones of all zones: got %+v and generation %d, want nothing", sets, c.generation)
+	}
+	sets = c.retainZones([]string{"example.tld."})
+	if len(sets) != 1 || sets[0].Zone != "other.tld." || !reflect.DeepEqual(sets[0].Removed, []string{"box.other.tld"}) {
+		t.Errorf("retainZones: got %+v, want box.other.tld removed", sets)
+	}
+	if _, ok := c.data["box.other.tld"]; ok {
+		t.Error("retainZones kept the names of the zone")
+	}
+
+	sets = c.dropZone("example.tld.")
+	if len(sets) != 1 || !reflect.DeepEqual(sets[0].Removed, []string{"web.example.tld"}) {
+		t.Errorf("dropZone: got %+v, want web.example.tld removed", sets)
+	}
+	if sets = c.dropZone("example.tld."); sets != nil {
+		t.Errorf("dropZone of a zone not in the cache: got %+v", sets)
+	}
+	if len(c.zones) != 0 || c.generation != 2 {
+		t.Errorf("got %d zones and generation %d, want 0 and 2", len(c.zones), c.generation)
+	}
+}
+
+func TestCacheUpdateGeneration(t *testing.T) {
+	c := &cache{zones: map[string]gethost.SOAwithRR{}, zoneAge: map[string]time.Time{}}
+	example := testZone(t, "example.tld.", 1, "web.example.tld. 300 IN A 10.0.0.1")
+	now := time.Now()
+
+	if sets := c.update(map[string]gethost.SOAwithRR{"example.tld.": example}, false, now); sets != nil || c.generation != 1 {
+		t.Errorf("first load: got %+v and generation %d, want no changes and 1", sets, c.generation)
+	}
+
+	// The same names and records with a new serial are not a change.
+	again := testZone(t, "example.tld.", 2, "web.example.tld. 300 IN A 10.0.0.1")
+	if sets := c.update(map[string]gethost.SOAwithRR{"example.tld.": again}, false, now); len(sets) != 0 || c.generation != 1 {
+		t.Errorf("unchanged zone: got %+v and generation %d, want no changes and 1", sets, c.generation)
+	}
+	if c.zones["example.tld."].SOA.Serial != 2 {
+		t.Error("the zone was not replaced")
+	}
+
+	changed := testZone(t, "example.tld.", 3, "web.example.tld. 300 IN A 10.0.0.2")
+	if sets := c.update(map[string]gethost.SOAwithRR{"example.tld.": changed}, false, now); len(sets) != 1 || c.generation != 2 {
+		t.Errorf("changed zone: got %+v and generation %d, want one change and 2", sets, c.generation)
+	}
+
+	// A zone without names is only a change of the zones.
+	empty := testZone(t, "empty.tld.", 1)
+	if sets := c.update(map[string]gethost.SOAwithRR{"empty.tld.": empty}, true, now); len(sets) != 0 || c.generation != 3 {
+		t.Errorf("added zone: got %+v and generation %d, want no changes and 3", sets, c.generation)
+	}
+	if len(c.zones) != 2 {
+		t.Errorf("partial update: got %d zones, want 2", len(c.zones))
+	}
+	if sets := c.update(map[string]gethost.SOAwithRR{"empty.tld.": empty}, true, now); len(sets) != 0 || c.generation != 3 {
+		t.Errorf("same zone again: got %+v and generation %d, want no changes and 3", sets, c.generation)
+	}
+}
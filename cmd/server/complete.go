@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+const (
+	defaultCompleteLimit = 100  // defaultCompleteLimit is the number of completions if limit is not given.
+	maxCompleteLimit     = 1000 // maxCompleteLimit is the largest number of completions.
+)
+
+// completions returns at most limit sorted names in sc that begin with word, or if no name does, that
+// match word in mode auto. It also returns the number of names there were.
+func (c *cache) completions(word string, sc *scope, limit int) ([]string, int, error) {
+	c.RLock()
+	defer c.RUnlock()
+	names, err := c.match(hostQuery{Query: word, Mode: gethost.ModePrefix, Scope: sc})
+	if err == nil && len(names) == 0 {
+		names, err = c.match(hostQuery{Query: word, Mode: gethost.ModeAuto, Scope: sc})
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(names)
+	if total > limit {
+		names = names[:limit]
+	}
+	return names, total, nil
+}
+
+// httpComplete is the search for shell completion, with the parameters q and limit. It always answers
+// with plain names, one per line, see gethost.PlainName.
+func httpComplete(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	params := r.URL.Query()
+	limit := defaultCompleteLimit
+	if l := params.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxCompleteLimit {
+			writeError(w, "limit must be between 1 and "+strconv.Itoa(maxCompleteLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	names, total, err := dnsRR.completions(params.Get("q"), scopeFrom(r), limit)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	atomic.AddInt64(&dnsRR.APIhits, 1)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	for _, n := range names {
+		fmt.Fprintln(w, gethost.PlainName(n))
+	}
+}
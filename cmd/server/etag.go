@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// cacheETag returns the ETag of the response to r. It is derived from the generation of the cache and
+// the annotations, the zone serials, and what selects the response: the URL, Accept and the token scope.
+// It is weak, as the body may be compressed or not.
+func cacheETag(r *http.Request) string {
+	h := sha256.New()
+	serials := zoneSerials(nil)
+	zones := make([]string, 0, len(serials))
+	for z := range serials {
+		zones = append(zones, z)
+	}
+	sort.Strings(zones)
+	for _, z := range zones {
+		fmt.Fprintf(h, "%s %d\n", z, serials[z])
+	}
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s\n", annotations.gen(), r.URL.Path, r.URL.RawQuery, r.Header.Get("Accept"))
+	if sc := scopeFrom(r); sc != nil {
+		scopeZones := make([]string, 0, len(sc.zones))
+		for z := range sc.zones {
+			scopeZones = append(scopeZones, z)
+		}
+		sort.Strings(scopeZones)
+		fmt.Fprintf(h, "%s %v %v\n", sc.name, scopeZones, sc.patterns)
+	}
+	return fmt.Sprintf(`W/"%d-%s"`, dnsRR.Generation(), hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+// etagMatch returns true if the If-None-Match header has etag, with weak comparison.
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// withETag sets an ETag on the response of handler, and answers 304 Not Modified instead of calling
+// handler when the client already has the response. Forced reloads are always answered.
+func withETag(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if forcesReload(r) {
+			handler(w, r)
+			return
+		}
+		etag := cacheETag(r)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Add("Vary", "Accept")
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatch(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response formats, selected with the format parameter or the Accept header.
+const (
+	formatJSON   = "json"
+	formatText   = "text"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// contentTypes is the Content-Type of every format.
+var contentTypes = map[string]string{
+	formatJSON:   "application/json",
+	formatText:   "text/plain; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+	formatCSV:    "text/csv; charset=utf-8",
+}
+
+// mediaTypes is the formats for media types in the Accept header.
+var mediaTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/plain":           formatText,
+	"text/dns":             formatText,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+	"application/jsonl":    formatNDJSON,
+	"text/csv":             formatCSV,
+	"*/*":                  formatJSON,
+	"application/*":        formatJSON,
+	"text/*":               formatText,
+}
+
+// negotiate returns the format of the response. The format parameter wins over the Accept header,
+// and JSON is used if neither is given.
+// The old zonefile parameter is the same as format=text.
+func negotiate(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		f = strings.ToLower(f)
+		if f == "txt" || f == "plain" {
+			f = formatText
+		}
+		if _, ok := contentTypes[f]; !ok {
+			return "", fmt.Errorf("unknown format %q, use one of json, text, ndjson or csv", f)
+		}
+		return f, nil
+	}
+	if boolParam(r, "zonefile") {
+		return formatText, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formatJSON, nil
+	}
+	format := ""
+	best := 0.0
+	for _, a := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(a))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypes[mt]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > best {
+			format, best = f, q
+		}
+	}
+	if format == "" {
+		return "", fmt.Errorf("can not produce %s, use one of application/json, text/plain, application/x-ndjson or text/csv", accept)
+	}
+	return format, nil
+}
+
+// formatError writes the error from negotiate.
+func formatError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.URL.Query().Get("format") != "" {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeError(w, err.Error(), http.StatusNotAcceptable)
+}
+
+// writeNames writes names, one per line in text and NDJSON, and with a header in CSV.
+func writeNames(w http.ResponseWriter, format string, names []string) {
+	switch format {
+	case formatText:
+		w.Header().Set("Content-Type", contentTypes[format])
+		for _, n := range names {
+			fmt.Fprintln(w, n)
+		}
+	case formatNDJSON:
+		w.Header().Set("Content-Type", contentTypes[format])
+		enc := json.NewEncoder(w)
+		for _, n := range names {
+			enc.Encode(n)
+		}
+	case formatCSV:
+		w.Header().Set("Content-Type", contentTypes[format])
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name"})
+		for _, n := range names {
+			cw.Write([]string{n})
+		}
+		cw.Flush()
+	default:
+		writeJSON(w, names)
+	}
+}
+
+// writeHostDetails writes details. Text is the records in zone file presentation format,
+// NDJSON is one object per line and CSV is one row per name with lists separated by space.
+func writeHostDetails(w http.ResponseWriter, format string, details []hostDetail) {
+	switch format {
+	case formatText:
+		w.Header().Set("Content-Type", contentTypes[format])
+		for _, d := range details {
+			for _, rr := range d.rrs {
+				fmt.Fprintln(w, rr.String())
+			}
+		}
+	case formatNDJSON:
+		w.Header().Set("Content-Type", contentTypes[format])
+		enc := json.NewEncoder(w)
+		for _, d := range details {
+			enc.Encode(d)
+		}
+	case formatCSV:
+		w.Header().Set("Content-Type", contentTypes[format])
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "zone", "serial", "types", "addresses", "cname", "records", "tags"})
+		for _, d := range details {
+			records := []string{}
+			for _, rr := range d.Records {
+				records = append(records, fmt.Sprintf("%s/%d/%s", rr.Type, rr.TTL, rr.Value))
+			}
+			tags := []string{}
+			for k, v := range d.Tags {
+				tags = append(tags, k+"="+v)
+			}
+			sort.Strings(tags)
+			cw.Write([]string{
+				d.Name,
+				d.Zone,
+				strconv.FormatUint(uint64(d.Serial), 10),
+				strings.Join(d.Types, " "),
+				strings.Join(d.Addresses, " "),
+				d.CNAME,
+				strings.Join(records, " "),
+				strings.Join(tags, " "),
+			})
+		}
+		cw.Flush()
+	default:
+		writeJSON(w, details)
+	}
+}
+
+// writeFlat writes v, that must marshal to a JSON object. Text and CSV is one "key: value"
+// or "key,value" per line, with nested keys joined by "/". NDJSON is the object on one line.
+func writeFlat(w http.ResponseWriter, format string, v interface{}) {
+	if format == formatJSON {
+		writeJSON(w, v)
+		return
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error:", err)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	if format == formatNDJSON {
+		fmt.Fprintln(w, string(j))
+		return
+	}
+
+	var m interface{}
+	json.Unmarshal(j, &m)
+	pairs := [][]string{}
+	flatten("", m, &pairs)
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	if format == formatCSV {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"key", "value"})
+		cw.WriteAll(pairs)
+		return
+	}
+	for _, p := range pairs {
+		fmt.Fprintf(w, "%s: %s\n", p[0], p[1])
+	}
+}
+
+func flatten(prefix string, v interface{}, pairs *[][]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			key := k
+			if prefix != "" {
+				key = prefix + "/" + k
+			}
+			flatten(key, v, pairs)
+		}
+	case []interface{}:
+		for i, v := range t {
+			flatten(prefix+"/"+strconv.Itoa(i), v, pairs)
+		}
+	case nil:
+		*pairs = append(*pairs, []string{prefix, ""})
+	case string:
+		*pairs = append(*pairs, []string{prefix, t})
+	default:
+		j, _ := json.Marshal(t)
+		*pairs = append(*pairs, []string{prefix, string(j)})
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/spetzreborn/get_host/internal/gethostpb"
+)
+
+// The gRPC service in gethost.proto is served by grpc-go through the HTTP/2 support of net/http,
+// so it listens, uses TLS and shuts down like the HTTP API.
+
+// grpcMethod is how calls of a method are checked before the method is called.
+type grpcMethod struct {
+	class string // class is the rate limit class, see limit, empty is not limited.
+	ready bool   // ready requires the cache to be ready, see requireReady.
+}
+
+var grpcMethods = map[string]grpcMethod{
+	pb.Gethost_Search_FullMethodName:     {class: limitSearch, ready: true},
+	pb.Gethost_GetRecords_FullMethodName: {class: limitSearch, ready: true},
+	pb.Gethost_Status_FullMethodName:     {class: limitStatus},
+	pb.Gethost_Watch_FullMethodName:      {},
+}
+
+// grpcServer returns the server of the gRPC service. It speaks HTTP/2 with TLS if tlsConfig is set,
+// and HTTP/2 without TLS otherwise.
+func grpcServer(tlsConfig *tls.Config) *http.Server {
+	gs := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, span, err := grpcCheck(ctx, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			defer span.Finish()
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, span, err := grpcCheck(ss.Context(), info.FullMethod)
+			if err != nil {
+				return err
+			}
+			defer span.Finish()
+			return handler(srv, grpcStream{ss, ctx})
+		}),
+	)
+	pb.RegisterGethostServer(gs, grpcService{})
+
+	srv := &http.Server{
+		Handler:   gs,
+		Protocols: &http.Protocols{},
+	}
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	if tlsConfig != nil {
+		srv.TLSConfig = tlsConfig.Clone()
+	}
+	srv.RegisterOnShutdown(watchers.closeAll)
+	return srv
+}
+
+// grpcCheck starts the span of a call, authenticates and rate limits it, and returns the context
+// for the method with the scope of the token and the span.
+func grpcCheck(ctx context.Context, fullMethod string) (context.Context, opentracing.Span, error) {
+	method := grpcMethods[fullMethod]
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	carrier := opentracing.TextMapCarrier{}
+	for k, v := range md {
+		if len(v) > 0 {
+			carrier[k] = v[0]
+		}
+	}
+	spanCtx, _ := tracer.Extract(opentracing.TextMap, carrier)
+	span := tracer.StartSpan("grpc"+fullMethod[strings.LastIndex(fullMethod, "/")+1:], ext.RPCServerOption(spanCtx))
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	config := liveConfig.get()
+	if len(config.Tokens) > 0 {
+		var auth string
+		if v := md.Get("authorization"); len(v) > 0 {
+			auth = v[0]
+		}
+		s, ok := authenticate(auth, config.Tokens)
+		if !ok {
+			span.Finish()
+			return nil, nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+		ctx = context.WithValue(ctx, scopeKey, s)
+	}
+	if method.class != "" {
+		var addr string
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			addr = p.Addr.String()
+		}
+		if ok, _, wait := limiter.allow(clientKeyOf(scopeOf(ctx), addr), []string{method.class}, config, time.Now()); !ok {
+			span.Finish()
+			return nil, nil, status.Errorf(codes.ResourceExhausted, "too many %s requests, retry in %s", method.class, wait.Round(time.Millisecond))
+		}
+	}
+	if method.ready {
+		if err := ready(); err != nil {
+			span.Finish()
+			return nil, nil, status.Error(codes.Unavailable, err.Error())
+		}
+	}
+	return ctx, span, nil
+}
+
+// grpcStream is a stream with the context from grpcCheck.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcService implements the methods of the gRPC service.
+type grpcService struct {
+	pb.UnimplementedGethostServer
+}
+
+// Search is the gRPC version of httpHostsV2.
+func (grpcService) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	config := liveConfig.get()
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit < 1 || limit > maxLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 1 and %d", maxLimit)
+	}
+	q := hostQuery{
+		Query: req.Query,
+		Mode:  req.Mode,
+		Zones: req.Zones,
+		Types: req.Types,
+		Tags:  parseTagFilters(req.Tags),
+		Scope: scopeOf(ctx),
+	}
+
+	hostnames, err := dnsRR.find(q)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	atomic.AddInt64(&dnsRR.APIhits, 1)
+	names, next, err := page(hostnames, req.Cursor, limit)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad cursor: %s", err)
+	}
+
+	resp := &pb.SearchResponse{
+		Total:      int32(len(hostnames)),
+		CacheAge:   int64(dnsRR.Age().Seconds()),
+		NextCursor: next,
+	}
+	if req.Detail {
+		details := dnsRR.details(names)
+		annotations.annotate(details)
+		for _, d := range details {
+			resp.Hosts = append(resp.Hosts, hostMessage(d))
+		}
+	} else {
+		resp.Names = names
+	}
+
+	if config.Verbose == true {
+		log.Printf("Send %d of %d matches for %q over gRPC\n", len(names), len(hostnames), q.Query)
+	}
+	return resp, nil
+}
+
+// GetRecords is the gRPC version of httpRecords.
+func (grpcService) GetRecords(ctx context.Context, req *pb.GetRecordsRequest) (*pb.Host, error) {
+	name := strings.TrimRight(req.Name, ".")
+
+	dnsRR.RLock()
+	d, ok := dnsRR.detail(name)
+	dnsRR.RUnlock()
+	if !ok || !scopeOf(ctx).visible(d.Zone, d.Name) {
+		return nil, status.Errorf(codes.NotFound, "No records for %s", name)
+	}
+	d.Tags = annotations.tagsFor(d.Name)
+	return hostMessage(d), nil
+}
+
+// Status is the gRPC version of httpStatus.
+func (grpcService) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	sc := scopeOf(ctx)
+	return &pb.StatusResponse{
+		Zones:       zoneSerials(sc),
+		Size:        int32(scopedSize(sc)),
+		CacheAge:    int64(dnsRR.Age().Seconds()),
+		Uptime:      int64(dnsRR.Uptime().Seconds()),
+		Hits:        atomic.LoadInt64(&dnsRR.APIhits),
+		RefreshRate: int32(liveConfig.get().TTL),
+	}, nil
+}
+
+// Watch is the gRPC version of httpWatch. The stream ends with status unavailable when the
+// watcher is disconnected, and the client may resume with last_event_id.
+func (grpcService) Watch(req *pb.WatchRequest, stream pb.Gethost_WatchServer) error {
+	ctx := stream.Context()
+	filter, err := watchFilter(req.Mode, req.Query, req.Zones, scopeOf(ctx))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub, backlog, resync := watchers.subscribe(int(req.LastEventId), filter)
+	if sub == nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	defer watchers.unsubscribe(sub)
+
+	if resync {
+		backlog = append([]event{{Type: eventResync, Time: time.Now()}}, backlog...)
+	}
+	for _, e := range backlog {
+		if err := stream.Send(eventMessage(e)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case e, ok := <-sub.c:
+			if !ok {
+				return status.Error(codes.Unavailable, "watch ended, resume with last_event_id")
+			}
+			if err := stream.Send(eventMessage(e)); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// hostMessage returns d as a Host message.
+func hostMessage(d hostDetail) *pb.Host {
+	h := &pb.Host{
+		Name:      d.Name,
+		Zone:      d.Zone,
+		Serial:    d.Serial,
+		Types:     d.Types,
+		Addresses: d.Addresses,
+		Cname:     d.CNAME,
+		Tags:      d.Tags,
+	}
+	for _, rec := range d.Records {
+		h.Records = append(h.Records, &pb.Record{Type: rec.Type, Ttl: rec.TTL, Value: rec.Value})
+	}
+	return h
+}
+
+// eventMessage returns e as an Event message.
+func eventMessage(e event) *pb.Event {
+	return &pb.Event{
+		Id:      int64(e.ID),
+		Type:    e.Type,
+		Time:    timestamppb.New(e.Time),
+		Zone:    e.Zone,
+		Name:    e.Name,
+		Serial:  e.Serial,
+		Records: int32(e.Records),
+		Error:   e.Error,
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/spetzreborn/get_host/internal/gethostpb"
+)
+
+func TestGRPCMessages(t *testing.T) {
+	d := hostDetail{
+		Name:      "web-1.example.tld",
+		Zone:      "example.tld.",
+		Serial:    101,
+		Types:     []string{"A", "TXT"},
+		Addresses: []string{"10.0.0.1"},
+		CNAME:     "",
+		Records:   []recordDetail{{Type: "A", TTL: 300, Value: "10.0.0.1"}, {Type: "TXT", TTL: 60, Value: `"hello"`}},
+		Tags:      map[string]string{"role": "web", "env": "prod"},
+	}
+	when := time.Date(2019, 7, 4, 7, 15, 0, 123456789, time.UTC)
+	e := event{ID: 12, Type: eventHostAdded, Time: when, Zone: "example.tld.", Name: "web-3.example.tld", Serial: 101, Records: 3, Error: "x"}
+
+	for _, m := range []proto.Message{hostMessage(d), eventMessage(e), &pb.SearchResponse{Names: []string{"a", "b"}, Total: 2, CacheAge: 5, NextCursor: "c"}} {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := m.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(b, got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(got, m) {
+			t.Errorf("round trip of %T:\ngot  %v\nwant %v", m, got, m)
+		}
+	}
+
+	h := hostMessage(d)
+	if h.Name != d.Name || h.Serial != 101 || len(h.Records) != 2 || h.Records[1].Ttl != 60 || !reflect.DeepEqual(h.Tags, d.Tags) {
+		t.Errorf("hostMessage(%+v) = %v", d, h)
+	}
+	if got := eventMessage(e).Time.AsTime(); !got.Equal(when) {
+		t.Errorf("event time is %s, want %s", got, when)
+	}
+}
+
+func TestGRPCCalls(t *testing.T) {
+	setupAPI(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpcServer(nil)
+	go srv.Serve(l)
+	defer srv.Close()
+
+	conn, err := grpc.NewClient("passthrough:///"+l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewGethostClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := client.Status(ctx, &pb.StatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Status without token: got %v, want Unauthenticated", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testToken)
+	search, err := client.Search(ctx, &pb.SearchRequest{Query: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web-1.example.tld", "web-2.example.tld"}; !reflect.DeepEqual(search.Names, want) || search.Total != 2 {
+		t.Errorf("Search: got %v, want %v", search, want)
+	}
+
+	search, err = client.Search(ctx, &pb.SearchRequest{Query: "web-1.example.tld", Mode: "exact", Detail: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(search.Hosts) != 1 || search.Hosts[0].Tags["role"] != "web" || search.Hosts[0].Addresses[0] != "10.0.0.1" {
+		t.Errorf("Search with detail: got %v", search)
+	}
+
+	if _, err := client.Search(ctx, &pb.SearchRequest{Query: "web", Limit: maxLimit + 1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Search with too large limit: got %v, want InvalidArgument", err)
+	}
+
+	host, err := client.GetRecords(ctx, &pb.GetRecordsRequest{Name: "box-1.other.tld."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.Zone != "other.tld." || host.Serial != 5 || len(host.Records) != 1 {
+		t.Errorf("GetRecords: got %v", host)
+	}
+	if _, err := client.GetRecords(ctx, &pb.GetRecordsRequest{Name: "nothing.example.tld"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRecords of a missing name: got %v, want NotFound", err)
+	}
+
+	st, err := client.Status(ctx, &pb.StatusRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]uint32{"example.tld.": 101, "other.tld.": 5}; !reflect.DeepEqual(st.Zones, want) || st.Size != 4 || st.RefreshRate != 900 {
+		t.Errorf("Status: got %v", st)
+	}
+}
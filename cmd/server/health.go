@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ready returns nil if the cache can answer questions, or why not.
+// The cache is not ready before it has been loaded, or when all zones are expired.
+func ready() error {
+	if !dnsRR.Loaded() {
+		return errors.New("cache has not been loaded yet")
+	}
+	expired, n := dnsRR.ExpiredZones()
+	if n > 0 && len(expired) == n {
+		return errors.New("all zones are expired: " + strings.Join(expired, ", "))
+	}
+	return nil
+}
+
+// requireReady answers 503 Service Unavailable instead of calling handler when the cache is not ready.
+func requireReady(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := ready(); err != nil {
+			w.Header().Set("Retry-After", "10")
+			writeError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// httpHealthz answers 200 OK as long as the server is running.
+func httpHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// httpReadyz answers 200 OK when the cache is ready, and 503 Service Unavailable when not.
+func httpReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := ready(); err != nil {
+		writeError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ready"})
+}
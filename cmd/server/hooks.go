@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+const (
+	hookQueueSize  = 100              // hookQueueSize is the number of change sets that may wait for one hook.
+	hookLogSize    = 100              // hookLogSize is the number of deliveries kept for /status.
+	hookMaxBackoff = 60 * time.Second // hookMaxBackoff is the longest wait between retries of a webhook.
+)
+
+var hooks = hookRunner{workers: map[string]chan hookJob{}}
+
+// hookRunner delivers change sets to hooks. Every hook has its own worker, so a slow hook
+// does not hold up the others, and change sets are delivered in order.
+type hookRunner struct {
+	sync.Mutex
+	workers    map[string]chan hookJob // workers is keyed by hook name.
+	deliveries []hookDelivery          // deliveries is the latest deliveries, oldest first.
+}
+
+// hookJob is a change set to deliver to a hook.
+type hookJob struct {
+	hook gethost.HookConfig
+	cs   changeSet
+}
+
+// hookDelivery is the outcome of delivering one change set to one hook.
+type hookDelivery struct {
+	Hook      string    `json:"hook"`
+	ChangeSet int       `json:"change_set"`
+	Zone      string    `json:"zone"`
+	Time      time.Time `json:"time"`
+	Attempts  int       `json:"attempts"`
+	Status    string    `json:"status"` // Status is ok, failed or dropped.
+	Error     string    `json:"error,omitempty"`
+}
+
+// fire queues the names in cs that match each hook in config.
+func (h *hookRunner) fire(config *gethost.Config, cs changeSet) {
+	for _, hook := range config.Hooks {
+		filtered, ok := filterHook(hook, cs)
+		if !ok {
+			continue
+		}
+		h.Lock()
+		c, ok := h.workers[hook.Name]
+		if !ok {
+			c = make(chan hookJob, hookQueueSize)
+			h.workers[hook.Name] = c
+			go h.work(c)
+		}
+		h.Unlock()
+
+		select {
+		case c <- hookJob{hook: hook, cs: filtered}:
+		default:
+			log.Printf("Hook %s is too far behind, dropped change set %d\n", hook.Name, cs.ID)
+			h.record(hookDelivery{Hook: hook.Name, ChangeSet: cs.ID, Zone: cs.Zone, Time: time.Now(), Status: "dropped"})
+		}
+	}
+}
+
+// filterHook returns the names in cs that hook fires for, and false if there are none.
+func filterHook(hook gethost.HookConfig, cs changeSet) (changeSet, bool) {
+	if len(hook.Zones) == 0 && len(hook.Patterns) == 0 {
+		return cs, !cs.empty()
+	}
+	zoneMatch := false
+	for _, z := range hook.Zones {
+		if strings.EqualFold(dns.Fqdn(z), cs.Zone) {
+			zoneMatch = true
+		}
+	}
+	filter := func(names []string) []string {
+		ret := []string{}
+		for _, n := range names {
+			if zoneMatch {
+				ret = append(ret, n)
+				continue
+			}
+			for _, p := range hook.Patterns {
+				if ok, _ := path.Match(p, n); ok {
+					ret = append(ret, n)
+					break
+				}
+			}
+		}
+		return ret
+	}
+	cs.Added = filter(cs.Added)
+	cs.Removed = filter(cs.Removed)
+	cs.Changed = filter(cs.Changed)
+	return cs, !cs.empty()
+}
+
+// work delivers the jobs from c one at a time.
+func (h *hookRunner) work(c chan hookJob) {
+	for job := range c {
+		d := hookDelivery{Hook: job.hook.Name, ChangeSet: job.cs.ID, Zone: job.cs.Zone, Time: time.Now()}
+		var err error
+		if job.hook.URL != "" {
+			d.Attempts, err = postHook(job.hook, job.cs)
+		} else {
+			d.Attempts, err = execHook(job.hook, job.cs)
+		}
+		d.Status = "ok"
+		if err != nil {
+			d.Status = "failed"
+			d.Error = err.Error()
+			log.Printf("Hook %s failed for change set %d after %d attempts: %s\n", job.hook.Name, job.cs.ID, d.Attempts, err)
+		}
+		h.record(d)
+	}
+}
+
+func (h *hookRunner) record(d hookDelivery) {
+	h.Lock()
+	defer h.Unlock()
+	h.deliveries = append(h.deliveries, d)
+	if len(h.deliveries) > hookLogSize {
+		h.deliveries = append([]hookDelivery(nil), h.deliveries[len(h.deliveries)-hookLogSize:]...)
+	}
+}
+
+// recent returns the latest deliveries, newest first.
+func (h *hookRunner) recent() []hookDelivery {
+	h.Lock()
+	defer h.Unlock()
+	ret := make([]hookDelivery, 0, len(h.deliveries))
+	for i := len(h.deliveries) - 1; i >= 0; i-- {
+		ret = append(ret, h.deliveries[i])
+	}
+	return ret
+}
+
+// sign returns the signature of body with secret, as sent in X-Gethost-Signature.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postHook posts cs to the webhook, and retries with doubled wait in between until it answers 2xx.
+// It returns the number of attempts.
+func postHook(hook gethost.HookConfig, cs changeSet) (int, error) {
+	body, err := json.Marshal(cs)
+	if err != nil {
+		return 0, err
+	}
+	client := &http.Client{Timeout: time.Duration(hook.Timeout) * time.Second}
+	backoff := time.Second
+	attempts := 0
+	for {
+		attempts++
+		err = postOnce(client, hook, cs, body)
+		if err == nil || attempts > hook.Retries {
+			return attempts, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > hookMaxBackoff {
+			backoff = hookMaxBackoff
+		}
+	}
+}
+
+func postOnce(client *http.Client, hook gethost.HookConfig, cs changeSet, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gethost")
+	req.Header.Set("X-Gethost-Event", "change")
+	req.Header.Set("X-Gethost-Delivery", strconv.Itoa(cs.ID))
+	if hook.Secret != "" {
+		req.Header.Set("X-Gethost-Signature", sign(hook.Secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("got " + resp.Status)
+	}
+	return nil
+}
+
+// execHook runs the command of hook with cs as JSON on stdin, and zone and serial in the environment.
+// Commands are not retried, as they may not be safe to run twice.
+func execHook(hook gethost.HookConfig, cs changeSet) (int, error) {
+	body, err := json.Marshal(cs)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(hook.Timeout)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"GETHOST_CHANGE_SET="+strconv.Itoa(cs.ID),
+		"GETHOST_ZONE="+cs.Zone,
+		"GETHOST_SERIAL="+strconv.FormatUint(uint64(cs.Serial), 10),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(out) > 0 {
+			return 1, fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+		}
+		return 1, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+const (
+	defaultLimit = 1000  // defaultLimit is the number of results in a page if limit is not given.
+	maxLimit     = 10000 // maxLimit is the largest number of results in a page.
+)
+
+// hostQuery is a search in the cache.
+type hostQuery struct {
+	Query string   // Query is matched against names according to Mode, see gethost.Matcher.
+	Mode  string   // Mode is the match mode, empty is gethost.ModeAuto.
+	Zones []string // Zones only includes names from these zones, if any.
+	Types []string // Types only includes names with at least one record of these types, if any.
+	Tags  []tagFilter
+	Scope *scope // Scope only includes names the token may see, nil is all names.
+}
+
+// hostsResponse is the envelope of /v2/hosts.
+type hostsResponse struct {
+	Results    interface{} `json:"results"`
+	Total      int         `json:"total"`       // Total is the number of matching names, in all pages.
+	CacheAge   int         `json:"cache_age"`   // CacheAge is the age of the cache in seconds.
+	NextCursor string      `json:"next_cursor"` // NextCursor gets the next page, empty on the last page.
+}
+
+// find returns the sorted names in the cache that match q.
+func (c *cache) find(q hostQuery) ([]string, error) {
+	c.RLock()
+	hostnames, err := c.match(q)
+	c.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return annotations.filter(hostnames, q.Tags), nil
+}
+
+// match returns the sorted names in the cache that match q, without the tag filters.
+// The caller must hold the lock.
+func (c *cache) match(q hostQuery) ([]string, error) {
+	match, err := gethost.Matcher(q.Mode, q.Query)
+	if err != nil {
+		return nil, err
+	}
+	zones := map[string]bool{}
+	for _, z := range q.Zones {
+		zones[dns.Fqdn(strings.ToLower(z))] = true
+	}
+	types := map[uint16]bool{}
+	for _, t := range q.Types {
+		rrtype, ok := dns.StringToType[strings.ToUpper(t)]
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", t)
+		}
+		types[rrtype] = true
+	}
+
+	found := map[string]bool{}
+	for zone, z := range c.zones {
+		if len(zones) > 0 && !zones[strings.ToLower(zone)] {
+			continue
+		}
+		for name, rrs := range z.RR {
+			if found[name] || !match(name) || !hasType(rrs, types) || !q.Scope.visible(zone, name) {
+				continue
+			}
+			found[name] = true
+		}
+	}
+
+	hostnames := make([]string, 0, len(found))
+	for name := range found {
+		hostnames = append(hostnames, name)
+	}
+	sort.Strings(hostnames)
+	return hostnames, nil
+}
+
+// hasType returns true if types is empty or any of rrs has one of types.
+func hasType(rrs []dns.RR, types map[uint16]bool) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, rr := range rrs {
+		if types[rr.Header().Rrtype] {
+			return true
+		}
+	}
+	return false
+}
+
+// page returns at most limit of the sorted names after cursor, and the cursor to the next page.
+// The cursor is the last name of the page, so it stays valid when the cache is updated.
+func page(names []string, cursor string, limit int) ([]string, string, error) {
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		last := string(b)
+		names = names[sort.SearchStrings(names, last):]
+		if len(names) > 0 && names[0] == last {
+			names = names[1:]
+		}
+	}
+	if len(names) <= limit {
+		return names, "", nil
+	}
+	names = names[:limit]
+	return names, base64.RawURLEncoding.EncodeToString([]byte(names[len(names)-1])), nil
+}
+
+// httpHostsV2 is the search with options as query parameters:
+// q, mode, zone, type, tag, detail, limit, cursor and nc.
+func httpHostsV2(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpHostsV2", ext.RPCServerOption(spanCtx))
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	defer span.Finish()
+
+	format, err := negotiate(r)
+	if err != nil {
+		formatError(w, r, err)
+		return
+	}
+
+	params := r.URL.Query()
+	limit := defaultLimit
+	if l := params.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, "limit must be between 1 and "+strconv.Itoa(maxLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if boolParam(r, "nc") {
+		log.Println("got nc flag")
+		if !forceRefresh(ctx, w, r, config) {
+			return
+		}
+	}
+
+	q := hostQuery{
+		Query: params.Get("q"),
+		Mode:  params.Get("mode"),
+		Zones: params["zone"],
+		Types: params["type"],
+		Tags:  parseTagFilters(params["tag"]),
+		Scope: scopeFrom(r),
+	}
+	hostnames, err := dnsRR.find(q)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	atomic.AddInt64(&dnsRR.APIhits, 1)
+
+	names, next, err := page(hostnames, params.Get("cursor"), limit)
+	if err != nil {
+		writeError(w, "bad cursor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ret := hostsResponse{
+		Results:    names,
+		Total:      len(hostnames),
+		CacheAge:   int(dnsRR.Age().Seconds()),
+		NextCursor: next,
+	}
+	var details []hostDetail
+	if boolParam(r, "detail") {
+		details = dnsRR.details(names)
+		annotations.annotate(details)
+		ret.Results = details
+	}
+
+	if config.Verbose == true {
+		log.Printf("Send %d of %d matches for %q\n", len(names), len(hostnames), q.Query)
+	}
+	if format == formatJSON {
+		writeJSON(w, ret)
+		return
+	}
+
+	// Other formats than JSON only have the results in the body, and the rest of the envelope as headers.
+	w.Header().Set("X-Total-Count", strconv.Itoa(ret.Total))
+	w.Header().Set("X-Cache-Age", strconv.Itoa(ret.CacheAge))
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	if details != nil {
+		writeHostDetails(w, format, details)
+		return
+	}
+	writeNames(w, format, names)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// serve runs srv on addrs, or on all interfaces on port if addrs is empty, until it is shut down.
+// TCP addresses use TLS if srv has a TLS configuration. Unix domain sockets never use TLS, access
+// to them is controlled with SocketMode and SocketOwner.
+func serve(name string, srv *http.Server, addrs []string, port int, config *gethost.Config) error {
+	if len(addrs) == 0 {
+		addrs = []string{":" + strconv.Itoa(port)}
+	}
+	listeners := []net.Listener{}
+	for _, a := range addrs {
+		l, err := listen(a, config)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("could not listen on %s: %s", a, err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	// Serve sets up a TLS configuration for HTTP/2, so whether to use TLS is decided before.
+	useTLS := srv.TLSConfig != nil
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			var err error
+			if useTLS && l.Addr().Network() != "unix" {
+				log.Println("Staring", name, "with TLS on", l.Addr())
+				err = srv.ServeTLS(l, "", "")
+			} else {
+				log.Println("Staring", name, "on", l.Addr())
+				err = srv.Serve(l)
+			}
+			if err != http.ErrServerClosed {
+				log.Fatal(err)
+			}
+		}(l)
+	}
+	return nil
+}
+
+// listen listens on a host:port address, or on a Unix domain socket for unix:/path.
+// The socket file is removed when the listener is closed.
+func listen(addr string, config *gethost.Config) (net.Listener, error) {
+	path, ok := gethost.SocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := setSocketOwner(path, config); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// removeStaleSocket removes a socket left by a server that did not shut down,
+// but not a socket that is in use or a file that is not a socket.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return errors.New(path + " exists and is not a socket")
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		c.Close()
+		return errors.New(path + " is in use by another server")
+	}
+	return os.Remove(path)
+}
+
+// setSocketOwner sets the configured mode, and owner and group if any, of the socket at path.
+func setSocketOwner(path string, config *gethost.Config) error {
+	mode, err := strconv.ParseUint(config.SocketMode, 8, 32)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		return err
+	}
+	if config.SocketOwner == "" {
+		return nil
+	}
+	uid, gid, err := lookupOwner(config.SocketOwner)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// lookupOwner returns the user and group id of "user", "user:group" or ":group", -1 is not given.
+func lookupOwner(owner string) (int, int, error) {
+	uid, gid := -1, -1
+	name, group := owner, ""
+	if i := strings.Index(owner, ":"); i >= 0 {
+		name, group = owner[:i], owner[i+1:]
+	}
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, err
+		}
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, err
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+var metrics = metricsRegistry{
+	routes:    map[string]*routeMetrics{},
+	transfers: map[string]*transferStats{},
+}
+
+// latencyBuckets is the upper bounds in seconds of the request latency histograms.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricsRegistry holds the metrics that are not read directly from the cache.
+type metricsRegistry struct {
+	sync.Mutex
+	routes     map[string]*routeMetrics  // routes is keyed by route path template.
+	transfers  map[string]*transferStats // transfers is keyed by zone.
+	refreshing int32                     // refreshing is the number of updates of the cache in progress, use atomic.
+	refreshes  uint64                    // refreshes is the number of completed updates of the cache.
+	failures   uint64                    // failures is the number of updates of the cache that failed.
+}
+
+// routeMetrics is the requests and latency histogram of one route.
+type routeMetrics struct {
+	codes   map[int]uint64
+	buckets []uint64 // buckets counts requests per latencyBuckets, not cumulative.
+	sum     float64
+	count   uint64
+}
+
+// transferHistorySize is the number of transfers kept in the history of each zone.
+const transferHistorySize = 20
+
+// transferStats is the outcome of zone transfers of one zone.
+type transferStats struct {
+	LastAttempt  time.Time
+	LastSuccess  time.Time
+	LastDuration time.Duration
+	LastError    string
+	NS           string // NS is the name server of the last transfer.
+	Transfers    uint64
+	Errors       uint64
+	History      []transferRecord // History is the latest transfers, newest last.
+}
+
+// transferRecord is one transfer of a zone.
+type transferRecord struct {
+	Time     time.Time `json:"time"`
+	Duration string    `json:"duration"`
+	NS       string    `json:"ns,omitempty"`
+	Serial   uint32    `json:"serial,omitempty"`
+	Records  int       `json:"records"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// request records one request to route.
+func (m *metricsRegistry) request(route string, code int, d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	rm, ok := m.routes[route]
+	if !ok {
+		rm = &routeMetrics{codes: map[int]uint64{}, buckets: make([]uint64, len(latencyBuckets))}
+		m.routes[route] = rm
+	}
+	rm.codes[code]++
+	s := d.Seconds()
+	rm.sum += s
+	rm.count++
+	for i, b := range latencyBuckets {
+		if s <= b {
+			rm.buckets[i]++
+			break
+		}
+	}
+}
+
+// transfer records the outcome of a zone transfer.
+func (m *metricsRegistry) transfer(res gethost.GetRRforZoneResult, now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	ts, ok := m.transfers[res.Zone]
+	if !ok {
+		ts = &transferStats{}
+		m.transfers[res.Zone] = ts
+	}
+	ts.LastAttempt = now
+	ts.LastDuration = res.Duration
+	ts.NS = res.NS
+	ts.Transfers++
+	rec := transferRecord{Time: now, Duration: res.Duration.String(), NS: res.NS, Records: len(res.SOA.RR)}
+	if res.SOA.SOA != nil {
+		rec.Serial = res.SOA.SOA.Serial
+	}
+	if res.Err != nil {
+		rec.Error = res.Err.Error()
+	}
+	ts.History = append(ts.History, rec)
+	if len(ts.History) > transferHistorySize {
+		ts.History = ts.History[len(ts.History)-transferHistorySize:]
+	}
+	if res.Err != nil {
+		ts.Errors++
+		ts.LastError = res.Err.Error()
+		return
+	}
+	ts.LastSuccess = now
+	ts.LastError = ""
+}
+
+// transferStatsFor returns a copy of the transfer statistics of zone.
+func (m *metricsRegistry) transferStatsFor(zone string) transferStats {
+	m.Lock()
+	defer m.Unlock()
+	ts, ok := m.transfers[zone]
+	if !ok {
+		return transferStats{}
+	}
+	ret := *ts
+	ret.History = append([]transferRecord(nil), ts.History...)
+	return ret
+}
+
+// refreshed records that an update of the cache is done.
+func (m *metricsRegistry) refreshed(err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.refreshes++
+	if err != nil {
+		m.failures++
+	}
+}
+
+// statusRecorder keeps the status code written to a http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush makes streamed responses work through the recorder.
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// metricsMiddleware records count, status code and latency of requests per route.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		route := r.URL.Path
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if t, err := cr.GetPathTemplate(); err == nil {
+				route = t
+			}
+		}
+		metrics.request(route, rec.code, time.Since(start))
+	})
+}
+
+// promWriter writes metrics in the Prometheus text exposition format.
+type promWriter struct {
+	w io.Writer
+}
+
+func (p promWriter) header(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p promWriter) sample(name string, labels []string, v float64) {
+	if len(labels) == 0 {
+		fmt.Fprintf(p.w, "%s %s\n", name, strconv.FormatFloat(v, 'f', -1, 64))
+		return
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabel(labels[i+1])+`"`)
+	}
+	fmt.Fprintf(p.w, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+func httpMetrics(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p := promWriter{w}
+
+	type zoneGauge struct {
+		records int
+		serial  uint32
+		loaded  time.Time
+	}
+	zones := map[string]zoneGauge{}
+	dnsRR.RLock()
+	for z, d := range dnsRR.zones {
+		zg := zoneGauge{records: len(d.RR), loaded: dnsRR.zoneAge[z]}
+		if d.SOA != nil {
+			zg.serial = d.SOA.Serial
+		}
+		zones[z] = zg
+	}
+	names := len(dnsRR.data)
+	loaded := !dnsRR.age.IsZero()
+	dnsRR.RUnlock()
+
+	rejected := limiter.rejectedCounts()
+
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	routes := make([]string, 0, len(metrics.routes))
+	for route := range metrics.routes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	p.header("gethost_http_requests_total", "counter", "Number of HTTP requests per route and status code.")
+	for _, route := range routes {
+		rm := metrics.routes[route]
+		codes := make([]int, 0, len(rm.codes))
+		for c := range rm.codes {
+			codes = append(codes, c)
+		}
+		sort.Ints(codes)
+		for _, c := range codes {
+			p.sample("gethost_http_requests_total", []string{"route", route, "code", strconv.Itoa(c)}, float64(rm.codes[c]))
+		}
+	}
+
+	p.header("gethost_http_request_duration_seconds", "histogram", "Latency of HTTP requests per route.")
+	for _, route := range routes {
+		rm := metrics.routes[route]
+		var cum uint64
+		for i, b := range latencyBuckets {
+			cum += rm.buckets[i]
+			p.sample("gethost_http_request_duration_seconds_bucket", []string{"route", route, "le", strconv.FormatFloat(b, 'g', -1, 64)}, float64(cum))
+		}
+		p.sample("gethost_http_request_duration_seconds_bucket", []string{"route", route, "le", "+Inf"}, float64(rm.count))
+		p.sample("gethost_http_request_duration_seconds_sum", []string{"route", route}, rm.sum)
+		p.sample("gethost_http_request_duration_seconds_count", []string{"route", route}, float64(rm.count))
+	}
+
+	zoneNames := append([]string(nil), config.Zones...)
+	sort.Strings(zoneNames)
+
+	p.header("gethost_zone_records", "gauge", "Number of names in the cache per zone.")
+	for _, z := range zoneNames {
+		p.sample("gethost_zone_records", []string{"zone", z}, float64(zones[z].records))
+	}
+	p.header("gethost_zone_serial", "gauge", "SOA serial of the zone in the cache.")
+	for _, z := range zoneNames {
+		if zg, ok := zones[z]; ok {
+			p.sample("gethost_zone_serial", []string{"zone", z}, float64(zg.serial))
+		}
+	}
+	p.header("gethost_zone_disabled", "gauge", "1 if the zone is disabled with the admin API.")
+	for _, z := range zoneNames {
+		v := 0.0
+		if disabledZones.disabled(z) {
+			v = 1
+		}
+		p.sample("gethost_zone_disabled", []string{"zone", z}, v)
+	}
+	p.header("gethost_zone_loaded_timestamp_seconds", "gauge", "Unix time the zone was last loaded into the cache, 0 if it is not in the cache.")
+	for _, z := range zoneNames {
+		p.sample("gethost_zone_loaded_timestamp_seconds", []string{"zone", z}, unixSeconds(zones[z].loaded))
+	}
+	p.header("gethost_zone_last_success_timestamp_seconds", "gauge", "Unix time of the last successful transfer of the zone, 0 if never. "+
+		"The transfer is not used if another zone failed in the same update, see gethost_zone_loaded_timestamp_seconds.")
+	for _, z := range zoneNames {
+		var t time.Time
+		if ts, ok := metrics.transfers[z]; ok {
+			t = ts.LastSuccess
+		}
+		p.sample("gethost_zone_last_success_timestamp_seconds", []string{"zone", z}, unixSeconds(t))
+	}
+	p.header("gethost_zone_transfer_duration_seconds", "gauge", "Duration of the last transfer of the zone.")
+	for _, z := range zoneNames {
+		if ts, ok := metrics.transfers[z]; ok {
+			p.sample("gethost_zone_transfer_duration_seconds", []string{"zone", z}, ts.LastDuration.Seconds())
+		}
+	}
+	p.header("gethost_zone_transfers_total", "counter", "Number of transfers of the zone.")
+	for _, z := range zoneNames {
+		var n uint64
+		if ts, ok := metrics.transfers[z]; ok {
+			n = ts.Transfers
+		}
+		p.sample("gethost_zone_transfers_total", []string{"zone", z}, float64(n))
+	}
+	p.header("gethost_zone_transfer_errors_total", "counter", "Number of failed transfers of the zone.")
+	for _, z := range zoneNames {
+		var n uint64
+		if ts, ok := metrics.transfers[z]; ok {
+			n = ts.Errors
+		}
+		p.sample("gethost_zone_transfer_errors_total", []string{"zone", z}, float64(n))
+	}
+
+	p.header("gethost_cache_names", "gauge", "Number of names in the cache.")
+	p.sample("gethost_cache_names", nil, float64(names))
+	p.header("gethost_cache_age_seconds", "gauge", "Age of the cache, -1 if it has never been loaded.")
+	if loaded {
+		p.sample("gethost_cache_age_seconds", nil, dnsRR.Age().Seconds())
+	} else {
+		p.sample("gethost_cache_age_seconds", nil, -1)
+	}
+	p.header("gethost_refresh_in_progress", "gauge", "Number of updates of the cache in progress.")
+	p.sample("gethost_refresh_in_progress", nil, float64(atomic.LoadInt32(&metrics.refreshing)))
+	p.header("gethost_refreshes_total", "counter", "Number of completed updates of the cache.")
+	p.sample("gethost_refreshes_total", nil, float64(metrics.refreshes))
+	p.header("gethost_refresh_failures_total", "counter", "Number of updates of the cache that failed.")
+	p.sample("gethost_refresh_failures_total", nil, float64(metrics.failures))
+	p.header("gethost_rate_limited_total", "counter", "Number of requests rejected by rate limits per class.")
+	for _, c := range []string{limitSearch, limitStatus, limitReload} {
+		p.sample("gethost_rate_limited_total", []string{"class", c}, float64(rejected[c]))
+	}
+	p.header("gethost_uptime_seconds", "gauge", "Seconds since the server started.")
+	p.sample("gethost_uptime_seconds", nil, dnsRR.Uptime().Seconds())
+}
@@ -0,0 +1,2257 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// httpOpenAPI serves the OpenAPI 3 document of the API.
+func httpOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, openAPISpec)
+}
+
+// openAPISpec describes all endpoints and their responses. Keep it in sync with handleRequests,
+// openapi_test.go checks that all routes are described and that responses match.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "gethost",
+    "description": "Searches host names in a cache of DNS zones, loaded with zone transfers.",
+    "version": "2"
+  },
+  "security": [
+    {},
+    {
+      "bearerAuth": []
+    }
+  ],
+  "paths": {
+    "/hosts/{id}": {
+      "get": {
+        "summary": "Names that match id",
+        "description": "Several whitespace separated terms must all match, see /v2/hosts for more options.",
+        "operationId": "getHosts",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Part of the names to find",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/format"
+          },
+          {
+            "$ref": "#/components/parameters/zonefile"
+          },
+          {
+            "$ref": "#/components/parameters/detail"
+          },
+          {
+            "$ref": "#/components/parameters/tag"
+          },
+          {
+            "$ref": "#/components/parameters/ifNoneMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Matching names, or detailed records with detail",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "oneOf": [
+                    {
+                      "$ref": "#/components/schemas/HostNames"
+                    },
+                    {
+                      "type": "array",
+                      "items": {
+                        "$ref": "#/components/schemas/HostDetail"
+                      }
+                    }
+                  ]
+                }
+              },
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "text/csv": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            }
+          },
+          "304": {
+            "$ref": "#/components/responses/NotModified"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "406": {
+            "$ref": "#/components/responses/NotAcceptable"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/hosts/{id}/nc": {
+      "get": {
+        "summary": "Names that match id, after a forced reload of the cache",
+        "operationId": "getHostsReload",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "Part of the names to find",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/format"
+          },
+          {
+            "$ref": "#/components/parameters/zonefile"
+          },
+          {
+            "$ref": "#/components/parameters/detail"
+          },
+          {
+            "$ref": "#/components/parameters/tag"
+          },
+          {
+            "name": "zone",
+            "in": "query",
+            "description": "Only reload this zone",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Matching names, or detailed records with detail",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "oneOf": [
+                    {
+                      "$ref": "#/components/schemas/HostNames"
+                    },
+                    {
+                      "type": "array",
+                      "items": {
+                        "$ref": "#/components/schemas/HostDetail"
+                      }
+                    }
+                  ]
+                }
+              },
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "text/csv": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "headers": {
+              "X-Cache-Refresh": {
+                "$ref": "#/components/headers/X-Cache-Refresh"
+              },
+              "Retry-After": {
+                "$ref": "#/components/headers/Retry-After"
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "406": {
+            "$ref": "#/components/responses/NotAcceptable"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/hosts/{id}/records": {
+      "get": {
+        "summary": "All records of one name",
+        "operationId": "getRecords",
+        "parameters": [
+          {
+            "name": "id",
+            "in": "path",
+            "required": true,
+            "description": "The name, with or without trailing dot",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/format"
+          },
+          {
+            "$ref": "#/components/parameters/ifNoneMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "The records and annotations of the name",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/HostDetail"
+                }
+              },
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "text/csv": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "headers": {
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            }
+          },
+          "304": {
+            "$ref": "#/components/responses/NotModified"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "406": {
+            "$ref": "#/components/responses/NotAcceptable"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/v2/hosts": {
+      "get": {
+        "summary": "Search names",
+        "operationId": "searchHosts",
+        "parameters": [
+          {
+            "name": "q",
+            "in": "query",
+            "description": "Matched against the names, empty matches all names",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/mode"
+          },
+          {
+            "name": "zone",
+            "in": "query",
+            "description": "Only names in these zones",
+            "schema": {
+              "type": "array",
+              "items": {
+                "type": "string"
+              }
+            },
+            "style": "form",
+            "explode": true
+          },
+          {
+            "name": "type",
+            "in": "query",
+            "description": "Only names with a record of these types",
+            "schema": {
+              "type": "array",
+              "items": {
+                "type": "string"
+              }
+            },
+            "style": "form",
+            "explode": true
+          },
+          {
+            "$ref": "#/components/parameters/tag"
+          },
+          {
+            "$ref": "#/components/parameters/detail"
+          },
+          {
+            "$ref": "#/components/parameters/format"
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "Results in each page",
+            "schema": {
+              "type": "integer",
+              "minimum": 1,
+              "maximum": 10000,
+              "default": 1000
+            }
+          },
+          {
+            "name": "cursor",
+            "in": "query",
+            "description": "next_cursor of the previous page",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "nc",
+            "in": "query",
+            "description": "Force reload of the cache, only of the zones in zone if given",
+            "schema": {
+              "type": "boolean"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/ifNoneMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "One page of results. Other formats than JSON only have the results, and the rest in headers.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/HostsResponse"
+                }
+              },
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "text/csv": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "headers": {
+              "X-Total-Count": {
+                "description": "Number of matching names, not for JSON",
+                "schema": {
+                  "type": "integer"
+                }
+              },
+              "X-Cache-Age": {
+                "description": "Age of the cache in seconds, not for JSON",
+                "schema": {
+                  "type": "integer"
+                }
+              },
+              "X-Next-Cursor": {
+                "description": "Cursor of the next page, not for JSON",
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "X-Cache-Refresh": {
+                "$ref": "#/components/headers/X-Cache-Refresh"
+              },
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            }
+          },
+          "304": {
+            "$ref": "#/components/responses/NotModified"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "406": {
+            "$ref": "#/components/responses/NotAcceptable"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/v2/hosts/batch": {
+      "post": {
+        "summary": "Many searches in one request",
+        "description": "All queries are run against the same cache, the batch is run again if the cache is updated while it runs. A query that can not be run has an error, the others are still run. Each query counts as one search for the rate limit.",
+        "operationId": "searchHostsBatch",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/BatchRequest"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Results in the order of the queries",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/BatchResponse"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/v2/complete": {
+      "get": {
+        "summary": "Names for shell completion",
+        "description": "Names that begin with q, or if no name does, that match q as in mode auto. Whitespace and control characters in names are written as \\DDD, so every name is one line and one shell word.",
+        "operationId": "complete",
+        "parameters": [
+          {
+            "name": "q",
+            "in": "query",
+            "description": "The word to complete",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "name": "limit",
+            "in": "query",
+            "description": "Most names to return",
+            "schema": {
+              "type": "integer",
+              "minimum": 1,
+              "maximum": 1000,
+              "default": 100
+            }
+          },
+          {
+            "$ref": "#/components/parameters/ifNoneMatch"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "One name per line",
+            "headers": {
+              "X-Total-Count": {
+                "description": "Number of matching names, more than returned when limit was reached",
+                "schema": {
+                  "type": "integer"
+                }
+              },
+              "ETag": {
+                "$ref": "#/components/headers/ETag"
+              }
+            },
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "304": {
+            "$ref": "#/components/responses/NotModified"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/v2/watch": {
+      "get": {
+        "summary": "Stream of changes as Server-Sent Events",
+        "description": "Every event has an id, and the event type as event. The data is an Event.",
+        "operationId": "watch",
+        "parameters": [
+          {
+            "name": "q",
+            "in": "query",
+            "description": "Only host events for matching names",
+            "schema": {
+              "type": "string"
+            }
+          },
+          {
+            "$ref": "#/components/parameters/mode"
+          },
+          {
+            "name": "zone",
+            "in": "query",
+            "description": "Only events for these zones",
+            "schema": {
+              "type": "array",
+              "items": {
+                "type": "string"
+              }
+            },
+            "style": "form",
+            "explode": true
+          },
+          {
+            "name": "last_event_id",
+            "in": "query",
+            "description": "Resume after this event",
+            "schema": {
+              "type": "integer"
+            }
+          },
+          {
+            "name": "Last-Event-ID",
+            "in": "header",
+            "description": "Resume after this event",
+            "schema": {
+              "type": "integer"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Events until the client disconnects",
+            "content": {
+              "text/event-stream": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/status": {
+      "get": {
+        "summary": "Status of the cache",
+        "operationId": "getStatus",
+        "parameters": [
+          {
+            "$ref": "#/components/parameters/format"
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Zones, size and age of the cache, and latest hook deliveries. Text and CSV are flattened keys.",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Status"
+                }
+              },
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "application/x-ndjson": {
+                "schema": {
+                  "type": "string"
+                }
+              },
+              "text/csv": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "406": {
+            "$ref": "#/components/responses/NotAcceptable"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          }
+        }
+      }
+    },
+    "/changes": {
+      "get": {
+        "summary": "Names that changed between updates of the cache",
+        "operationId": "getChanges",
+        "parameters": [
+          {
+            "name": "since",
+            "in": "query",
+            "description": "RFC3339 timestamp, unix timestamp or duration back in time",
+            "schema": {
+              "type": "string"
+            }
+          }
+        ],
+        "responses": {
+          "200": {
+            "description": "Change sets, oldest first",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ChangeSet"
+                  }
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          }
+        }
+      }
+    },
+    "/version": {
+      "get": {
+        "summary": "Build information",
+        "operationId": "getVersion",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "200": {
+            "description": "One \"key: value\" per line",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "Liveness",
+        "operationId": "getHealthz",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "200": {
+            "description": "The server is running",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Health"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness",
+        "operationId": "getReadyz",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "200": {
+            "description": "The cache can answer questions",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Health"
+                }
+              }
+            }
+          },
+          "503": {
+            "$ref": "#/components/responses/NotReady"
+          }
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "summary": "Metrics in Prometheus text format",
+        "operationId": "getMetrics",
+        "responses": {
+          "200": {
+            "description": "Metrics",
+            "content": {
+              "text/plain": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "429": {
+            "$ref": "#/components/responses/TooManyRequests"
+          }
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "operationId": "getOpenAPI",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "200": {
+            "description": "OpenAPI 3 document",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "object"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/": {
+      "get": {
+        "summary": "Redirect to the web UI",
+        "operationId": "getRoot",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "302": {
+            "description": "Redirect to /ui/",
+            "headers": {
+              "Location": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            },
+            "content": {
+              "text/html": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/ui/": {
+      "get": {
+        "summary": "Web UI",
+        "description": "A single page that searches with /v2/hosts and /hosts/{id}/records, and shows /status. It asks for a token when one is needed.",
+        "operationId": "getUI",
+        "security": [
+          {}
+        ],
+        "responses": {
+          "200": {
+            "description": "The page",
+            "content": {
+              "text/html": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "/annotations": {
+      "get": {
+        "summary": "All annotations",
+        "operationId": "getAnnotations",
+        "responses": {
+          "200": {
+            "description": "Tags per name or pattern",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Annotations"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          }
+        }
+      }
+    },
+    "/annotations/import": {
+      "post": {
+        "summary": "Add annotations from CSV",
+        "operationId": "importAnnotations",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "text/csv": {
+              "schema": {
+                "type": "string",
+                "description": "Rows of pattern,tag,value, with an optional header"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Number of names or patterns imported",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ImportResult"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/annotations/{pattern}": {
+      "parameters": [
+        {
+          "name": "pattern",
+          "in": "path",
+          "required": true,
+          "description": "Name or pattern, see path.Match",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "Tags of a name or pattern",
+        "operationId": "getAnnotation",
+        "responses": {
+          "200": {
+            "description": "Tags",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Tags"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      },
+      "put": {
+        "summary": "Replace tags of a name or pattern",
+        "operationId": "replaceAnnotation",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Tags"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Tags",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Tags"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "summary": "Add tags to a name or pattern",
+        "operationId": "addAnnotation",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "$ref": "#/components/schemas/Tags"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "Tags",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Tags"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Remove all tags of a name or pattern",
+        "operationId": "deleteAnnotation",
+        "responses": {
+          "204": {
+            "description": "Removed"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/annotations/{pattern}/{tag}": {
+      "parameters": [
+        {
+          "name": "pattern",
+          "in": "path",
+          "required": true,
+          "description": "Name or pattern, see path.Match",
+          "schema": {
+            "type": "string"
+          }
+        },
+        {
+          "name": "tag",
+          "in": "path",
+          "required": true,
+          "description": "Tag",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "Value of one tag",
+        "operationId": "getTag",
+        "responses": {
+          "200": {
+            "description": "Value",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "string"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      },
+      "put": {
+        "summary": "Set one tag",
+        "operationId": "setTag",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "string"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "All tags of the name or pattern",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Tags"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "post": {
+        "summary": "Set one tag",
+        "operationId": "addTag",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "string"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {
+            "description": "All tags of the name or pattern",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/Tags"
+                }
+              }
+            }
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      },
+      "delete": {
+        "summary": "Remove one tag",
+        "operationId": "deleteTag",
+        "responses": {
+          "204": {
+            "description": "Removed"
+          },
+          "400": {
+            "$ref": "#/components/responses/BadRequest"
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "500": {
+            "$ref": "#/components/responses/InternalError"
+          }
+        }
+      }
+    },
+    "/admin/zones": {
+      "get": {
+        "summary": "All zones",
+        "operationId": "adminZones",
+        "responses": {
+          "200": {
+            "description": "Zones",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "type": "array",
+                  "items": {
+                    "$ref": "#/components/schemas/ZoneInfo"
+                  }
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          }
+        }
+      }
+    },
+    "/admin/zones/{zone}": {
+      "parameters": [
+        {
+          "name": "zone",
+          "in": "path",
+          "required": true,
+          "description": "Zone, the trailing dot may be left out",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "get": {
+        "summary": "One zone",
+        "operationId": "adminZone",
+        "responses": {
+          "200": {
+            "description": "Zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ZoneInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      }
+    },
+    "/admin/zones/{zone}/refresh": {
+      "parameters": [
+        {
+          "name": "zone",
+          "in": "path",
+          "required": true,
+          "description": "Zone, the trailing dot may be left out",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "post": {
+        "summary": "Transfer the zone now",
+        "operationId": "adminRefresh",
+        "responses": {
+          "200": {
+            "description": "Zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ZoneInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "409": {
+            "$ref": "#/components/responses/Conflict"
+          },
+          "502": {
+            "$ref": "#/components/responses/BadGateway"
+          }
+        }
+      }
+    },
+    "/admin/zones/{zone}/disable": {
+      "parameters": [
+        {
+          "name": "zone",
+          "in": "path",
+          "required": true,
+          "description": "Zone, the trailing dot may be left out",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "post": {
+        "summary": "Stop transfers of the zone and remove it from the cache",
+        "operationId": "adminDisable",
+        "responses": {
+          "200": {
+            "description": "Zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ZoneInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      }
+    },
+    "/admin/zones/{zone}/enable": {
+      "parameters": [
+        {
+          "name": "zone",
+          "in": "path",
+          "required": true,
+          "description": "Zone, the trailing dot may be left out",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "post": {
+        "summary": "Enable the zone again and transfer it",
+        "operationId": "adminEnable",
+        "responses": {
+          "200": {
+            "description": "Zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ZoneInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          },
+          "502": {
+            "$ref": "#/components/responses/BadGateway"
+          }
+        }
+      }
+    },
+    "/admin/zones/{zone}/cache": {
+      "parameters": [
+        {
+          "name": "zone",
+          "in": "path",
+          "required": true,
+          "description": "Zone, the trailing dot may be left out",
+          "schema": {
+            "type": "string"
+          }
+        }
+      ],
+      "delete": {
+        "summary": "Remove the cached data of the zone",
+        "operationId": "adminCache",
+        "responses": {
+          "200": {
+            "description": "Zone",
+            "content": {
+              "application/json": {
+                "schema": {
+                  "$ref": "#/components/schemas/ZoneInfo"
+                }
+              }
+            }
+          },
+          "401": {
+            "$ref": "#/components/responses/Unauthorized"
+          },
+          "403": {
+            "$ref": "#/components/responses/Forbidden"
+          },
+          "404": {
+            "$ref": "#/components/responses/NotFound"
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": {
+        "type": "http",
+        "scheme": "bearer",
+        "description": "Required for all but /, /ui/, /healthz, /readyz, /version and /openapi.json when tokens are configured"
+      }
+    },
+    "parameters": {
+      "format": {
+        "name": "format",
+        "in": "query",
+        "description": "Response format, wins over the Accept header",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "json",
+            "text",
+            "txt",
+            "plain",
+            "ndjson",
+            "csv"
+          ]
+        }
+      },
+      "zonefile": {
+        "name": "zonefile",
+        "in": "query",
+        "description": "Same as format=text with detail",
+        "schema": {
+          "type": "boolean"
+        }
+      },
+      "detail": {
+        "name": "detail",
+        "in": "query",
+        "description": "Detailed records instead of names",
+        "schema": {
+          "type": "boolean"
+        }
+      },
+      "tag": {
+        "name": "tag",
+        "in": "query",
+        "description": "Only names with this annotation, as tag or tag=value",
+        "schema": {
+          "type": "array",
+          "items": {
+            "type": "string"
+          }
+        },
+        "style": "form",
+        "explode": true
+      },
+      "mode": {
+        "name": "mode",
+        "in": "query",
+        "description": "How q is matched",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "auto",
+            "substring",
+            "prefix",
+            "exact",
+            "tokens"
+          ],
+          "default": "auto"
+        }
+      },
+      "ifNoneMatch": {
+        "name": "If-None-Match",
+        "in": "header",
+        "description": "ETag of a response the client has, answered with 304 if it is still current",
+        "schema": {
+          "type": "string"
+        }
+      }
+    },
+    "headers": {
+      "X-Cache-Refresh": {
+        "description": "Outcome of a forced reload",
+        "schema": {
+          "type": "string",
+          "enum": [
+            "refreshed",
+            "shared",
+            "skipped",
+            "failed"
+          ]
+        }
+      },
+      "Retry-After": {
+        "description": "Seconds until the request may be done again",
+        "schema": {
+          "type": "integer"
+        }
+      },
+      "ETag": {
+        "description": "Changes when the cache, the zone serials or the annotations change",
+        "schema": {
+          "type": "string"
+        }
+      }
+    },
+    "responses": {
+      "BadRequest": {
+        "description": "Invalid parameters",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "Unauthorized": {
+        "description": "Missing or invalid token",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "Forbidden": {
+        "description": "The token may not do this",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "NotFound": {
+        "description": "Not found",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "NotAcceptable": {
+        "description": "None of the formats in Accept can be produced",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "Conflict": {
+        "description": "The zone is disabled",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "TooManyRequests": {
+        "description": "Rate limited",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        },
+        "headers": {
+          "Retry-After": {
+            "$ref": "#/components/headers/Retry-After"
+          }
+        }
+      },
+      "InternalError": {
+        "description": "Internal error",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "BadGateway": {
+        "description": "The zone transfer failed",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        }
+      },
+      "NotReady": {
+        "description": "The cache is not loaded, or all zones are expired",
+        "content": {
+          "application/json": {
+            "schema": {
+              "$ref": "#/components/schemas/Error"
+            }
+          }
+        },
+        "headers": {
+          "Retry-After": {
+            "$ref": "#/components/headers/Retry-After"
+          }
+        }
+      },
+      "NotModified": {
+        "description": "The response in If-None-Match is still current",
+        "headers": {
+          "ETag": {
+            "$ref": "#/components/headers/ETag"
+          }
+        }
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": [
+          "error"
+        ],
+        "properties": {
+          "error": {
+            "type": "string"
+          }
+        }
+      },
+      "Health": {
+        "type": "object",
+        "required": [
+          "status"
+        ],
+        "properties": {
+          "status": {
+            "type": "string",
+            "enum": [
+              "ok",
+              "ready"
+            ]
+          }
+        }
+      },
+      "HostNames": {
+        "type": "array",
+        "items": {
+          "type": "string"
+        }
+      },
+      "Record": {
+        "type": "object",
+        "required": [
+          "type",
+          "ttl",
+          "value"
+        ],
+        "properties": {
+          "type": {
+            "type": "string"
+          },
+          "ttl": {
+            "type": "integer"
+          },
+          "value": {
+            "type": "string"
+          }
+        }
+      },
+      "HostDetail": {
+        "type": "object",
+        "required": [
+          "name",
+          "zone",
+          "serial",
+          "types",
+          "addresses",
+          "records"
+        ],
+        "properties": {
+          "name": {
+            "type": "string"
+          },
+          "zone": {
+            "type": "string"
+          },
+          "serial": {
+            "type": "integer",
+            "description": "SOA serial of the zone when the name was loaded"
+          },
+          "types": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "addresses": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "cname": {
+            "type": "string"
+          },
+          "records": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/Record"
+            }
+          },
+          "tags": {
+            "$ref": "#/components/schemas/Tags"
+          }
+        }
+      },
+      "HostsResponse": {
+        "type": "object",
+        "required": [
+          "results",
+          "total",
+          "cache_age",
+          "next_cursor"
+        ],
+        "properties": {
+          "results": {
+            "oneOf": [
+              {
+                "$ref": "#/components/schemas/HostNames"
+              },
+              {
+                "type": "array",
+                "items": {
+                  "$ref": "#/components/schemas/HostDetail"
+                }
+              }
+            ]
+          },
+          "total": {
+            "type": "integer"
+          },
+          "cache_age": {
+            "type": "integer",
+            "description": "Seconds"
+          },
+          "next_cursor": {
+            "type": "string",
+            "description": "Empty on the last page"
+          }
+        }
+      },
+      "BatchRequest": {
+        "type": "object",
+        "required": [
+          "queries"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "queries": {
+            "type": "array",
+            "minItems": 1,
+            "maxItems": 1000,
+            "items": {
+              "$ref": "#/components/schemas/BatchQuery"
+            }
+          }
+        }
+      },
+      "BatchQuery": {
+        "type": "object",
+        "required": [
+          "q"
+        ],
+        "additionalProperties": false,
+        "properties": {
+          "q": {
+            "type": "string",
+            "description": "Matched against the names, empty matches all names"
+          },
+          "mode": {
+            "type": "string",
+            "enum": [
+              "auto",
+              "substring",
+              "prefix",
+              "exact",
+              "tokens"
+            ],
+            "default": "auto"
+          },
+          "zone": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "type": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "tag": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "limit": {
+            "type": "integer",
+            "minimum": 1,
+            "maximum": 10000,
+            "default": 1000
+          },
+          "detail": {
+            "type": "boolean"
+          }
+        }
+      },
+      "BatchResult": {
+        "type": "object",
+        "required": [
+          "q",
+          "results",
+          "total"
+        ],
+        "properties": {
+          "q": {
+            "type": "string"
+          },
+          "results": {
+            "oneOf": [
+              {
+                "$ref": "#/components/schemas/HostNames"
+              },
+              {
+                "type": "array",
+                "items": {
+                  "$ref": "#/components/schemas/HostDetail"
+                }
+              }
+            ]
+          },
+          "total": {
+            "type": "integer",
+            "description": "Number of matching names, more than in results when limit was reached"
+          },
+          "error": {
+            "type": "string",
+            "description": "Why the query could not be run"
+          }
+        }
+      },
+      "BatchResponse": {
+        "type": "object",
+        "required": [
+          "results",
+          "cache_age",
+          "generation"
+        ],
+        "properties": {
+          "results": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/BatchResult"
+            }
+          },
+          "cache_age": {
+            "type": "integer",
+            "description": "Seconds"
+          },
+          "generation": {
+            "type": "integer",
+            "description": "Changes when names or records in the cache change, or zones are added or removed"
+          }
+        }
+      },
+      "Status": {
+        "type": "object",
+        "required": [
+          "Zones",
+          "Size",
+          "Age",
+          "Uptime",
+          "Hits",
+          "RefreschRate"
+        ],
+        "properties": {
+          "Zones": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "object",
+              "required": [
+                "serial",
+                "age"
+              ],
+              "properties": {
+                "serial": {
+                  "type": "integer"
+                },
+                "age": {
+                  "type": "string",
+                  "description": "How long ago the zone was loaded"
+                }
+              }
+            }
+          },
+          "Size": {
+            "type": "integer"
+          },
+          "Age": {
+            "type": "string"
+          },
+          "Uptime": {
+            "type": "string"
+          },
+          "Hits": {
+            "type": "integer"
+          },
+          "RefreschRate": {
+            "type": "integer"
+          },
+          "Hooks": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/HookDelivery"
+            }
+          }
+        }
+      },
+      "HookDelivery": {
+        "type": "object",
+        "required": [
+          "hook",
+          "change_set",
+          "zone",
+          "time",
+          "attempts",
+          "status"
+        ],
+        "properties": {
+          "hook": {
+            "type": "string"
+          },
+          "change_set": {
+            "type": "integer"
+          },
+          "zone": {
+            "type": "string"
+          },
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "attempts": {
+            "type": "integer"
+          },
+          "status": {
+            "type": "string",
+            "enum": [
+              "ok",
+              "failed",
+              "dropped"
+            ]
+          },
+          "error": {
+            "type": "string"
+          }
+        }
+      },
+      "ChangeSet": {
+        "type": "object",
+        "required": [
+          "id",
+          "time",
+          "zone",
+          "old_serial",
+          "serial",
+          "added",
+          "removed",
+          "changed"
+        ],
+        "properties": {
+          "id": {
+            "type": "integer"
+          },
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "zone": {
+            "type": "string"
+          },
+          "old_serial": {
+            "type": "integer"
+          },
+          "serial": {
+            "type": "integer"
+          },
+          "added": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "removed": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          },
+          "changed": {
+            "type": "array",
+            "items": {
+              "type": "string"
+            }
+          }
+        }
+      },
+      "Event": {
+        "type": "object",
+        "required": [
+          "id",
+          "type",
+          "time"
+        ],
+        "properties": {
+          "id": {
+            "type": "integer"
+          },
+          "type": {
+            "type": "string",
+            "enum": [
+              "host-added",
+              "host-removed",
+              "host-changed",
+              "zone-refreshed",
+              "zone-failed",
+              "resync"
+            ]
+          },
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "zone": {
+            "type": "string"
+          },
+          "name": {
+            "type": "string"
+          },
+          "serial": {
+            "type": "integer"
+          },
+          "records": {
+            "type": "integer"
+          },
+          "error": {
+            "type": "string"
+          }
+        }
+      },
+      "Tags": {
+        "type": "object",
+        "additionalProperties": {
+          "type": "string"
+        }
+      },
+      "Annotations": {
+        "type": "object",
+        "additionalProperties": {
+          "$ref": "#/components/schemas/Tags"
+        }
+      },
+      "ImportResult": {
+        "type": "object",
+        "required": [
+          "imported"
+        ],
+        "properties": {
+          "imported": {
+            "type": "integer"
+          }
+        }
+      },
+      "TransferRecord": {
+        "type": "object",
+        "required": [
+          "time",
+          "duration",
+          "records"
+        ],
+        "properties": {
+          "time": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "duration": {
+            "type": "string"
+          },
+          "ns": {
+            "type": "string"
+          },
+          "serial": {
+            "type": "integer"
+          },
+          "records": {
+            "type": "integer"
+          },
+          "error": {
+            "type": "string"
+          }
+        }
+      },
+      "ZoneInfo": {
+        "type": "object",
+        "required": [
+          "zone",
+          "disabled",
+          "ns",
+          "serial",
+          "records",
+          "last_error",
+          "transfers",
+          "errors",
+          "history"
+        ],
+        "properties": {
+          "zone": {
+            "type": "string"
+          },
+          "disabled": {
+            "type": "boolean"
+          },
+          "ns": {
+            "type": "string"
+          },
+          "serial": {
+            "type": "integer"
+          },
+          "records": {
+            "type": "integer"
+          },
+          "loaded_at": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "last_attempt": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "last_success": {
+            "type": "string",
+            "format": "date-time"
+          },
+          "last_error": {
+            "type": "string"
+          },
+          "transfers": {
+            "type": "integer"
+          },
+          "errors": {
+            "type": "integer"
+          },
+          "history": {
+            "type": "array",
+            "items": {
+              "$ref": "#/components/schemas/TransferRecord"
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
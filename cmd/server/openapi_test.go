@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+	opentracing "github.com/opentracing/opentracing-go"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+const testToken = "test-admin-token"
+
+// testZone returns a zone with serial and the records in rrs, in zone file presentation format.
+func testZone(t *testing.T, zone string, serial uint32, rrs ...string) gethost.SOAwithRR {
+	soa, err := dns.NewRR(fmt.Sprintf("%s 300 IN SOA ns.%s admin.%s %d 3600 600 86400 300", zone, zone, zone, serial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := gethost.SOAwithRR{SOA: soa.(*dns.SOA), RR: map[string][]dns.RR{}}
+	for _, s := range rrs {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.TrimRight(rr.Header().Name, ".")
+		z.RR[name] = append(z.RR[name], rr)
+	}
+	return z
+}
+
+// setupAPI loads the cache with test data, and returns the handler of the server.
+func setupAPI(t *testing.T) http.Handler {
+	tracer = opentracing.NoopTracer{}
+	config := &gethost.Config{
+		Zones:              []string{"example.tld.", "other.tld."},
+		TTL:                900,
+		ChangeLogSize:      100,
+		MinRefreshInterval: 10,
+		Tokens:             []gethost.TokenConfig{{Name: "admin", Hash: hashToken(testToken), Admin: true}},
+	}
+	liveConfig.set(config, time.Now())
+	disabledZones.retain(nil)
+
+	zones := map[string]gethost.SOAwithRR{
+		"example.tld.": testZone(t, "example.tld.", 101,
+			"web-1.example.tld. 300 IN A 10.0.0.1",
+			"web-2.example.tld. 300 IN A 10.0.0.2",
+			"www.example.tld. 300 IN CNAME web-1.example.tld."),
+		"other.tld.": testZone(t, "other.tld.", 5, "box-1.other.tld. 300 IN A 10.9.0.1"),
+	}
+	now := time.Now()
+	dnsRR.Lock()
+	dnsRR.zones = zones
+	dnsRR.zoneAge = map[string]time.Time{"example.tld.": now, "other.tld.": now}
+	dnsRR.data, dnsRR.soas = mergeZones(zones)
+	dnsRR.age = now
+	dnsRR.Unlock()
+
+	for z, d := range zones {
+		metrics.transfer(gethost.GetRRforZoneResult{Zone: z, NS: "127.0.0.1", SOA: d, Duration: time.Millisecond}, now)
+	}
+	changes.add(changeSet{Time: now, Zone: "example.tld.", OldSerial: 100, Serial: 101,
+		Added: []string{"web-2.example.tld"}, Removed: []string{}, Changed: []string{}})
+	hooks.record(hookDelivery{Hook: "test", ChangeSet: 1, Zone: "example.tld.", Time: now, Attempts: 1, Status: "ok"})
+	if err := annotations.set(map[string]map[string]string{"web-1.example.tld": {"role": "web"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	return handleRequests(config).Handler
+}
+
+// apiSpec is the parsed openAPISpec.
+type apiSpec map[string]interface{}
+
+func loadSpec(t *testing.T) apiSpec {
+	spec := apiSpec{}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		t.Fatal("openAPISpec is not valid JSON:", err)
+	}
+	if v, _ := spec["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Fatal("openAPISpec is not OpenAPI 3:", v)
+	}
+	return spec
+}
+
+// resolve follows $ref in v.
+func (s apiSpec) resolve(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	for m != nil {
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		var cur interface{} = map[string]interface{}(s)
+		for _, p := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			c, _ := cur.(map[string]interface{})
+			cur = c[p]
+		}
+		m, _ = cur.(map[string]interface{})
+	}
+	return m
+}
+
+// path returns the path in the spec that urlPath matches, literal segments win over templates.
+func (s apiSpec) path(urlPath string) (string, bool) {
+	segs := strings.Split(urlPath, "/")
+	best, bestScore := "", -1
+	paths, _ := s["paths"].(map[string]interface{})
+	for p := range paths {
+		psegs := strings.Split(p, "/")
+		if len(psegs) != len(segs) {
+			continue
+		}
+		score := 0
+		for i, ps := range psegs {
+			if strings.HasPrefix(ps, "{") {
+				continue
+			}
+			if ps != segs[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// operation returns the operation for method on the spec path p.
+func (s apiSpec) operation(p, method string) map[string]interface{} {
+	paths, _ := s["paths"].(map[string]interface{})
+	item := s.resolve(paths[p])
+	return s.resolve(item[strings.ToLower(method)])
+}
+
+// validate checks v against schema, and returns the first difference.
+func (s apiSpec) validate(schema map[string]interface{}, v interface{}, at string) error {
+	schema = s.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, o := range oneOf {
+			if s.validate(s.resolve(o), v, at) == nil {
+				matches++
+			}
+		}
+		// Empty arrays match all array alternatives.
+		if matches == 0 || (matches > 1 && !isEmptyArray(v)) {
+			return fmt.Errorf("%s: matches %d of oneOf", at, matches)
+		}
+		return nil
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not in %v", at, v, enum)
+		}
+	}
+
+	switch schema["type"] {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v is not an object", at, v)
+		}
+		if req, ok := schema["required"].([]interface{}); ok {
+			for _, r := range req {
+				if _, ok := m[r.(string)]; !ok {
+					return fmt.Errorf("%s: missing required %s", at, r)
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := props[k]; ok {
+				if err := s.validate(s.resolve(p), m[k], at+"."+k); err != nil {
+					return err
+				}
+				continue
+			}
+			ap, ok := schema["additionalProperties"]
+			if !ok && props != nil {
+				return fmt.Errorf("%s: %s is not in the schema", at, k)
+			}
+			if err := s.validate(s.resolve(ap), m[k], at+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %v is not an array", at, v)
+		}
+		for i, e := range a {
+			if err := s.validate(s.resolve(schema["items"]), e, at+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: %v is not a string", at, v)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+	case "integer":
+		f, ok := v.(float64)
+		if !ok || f != math.Trunc(f) {
+			return fmt.Errorf("%s: %v is not an integer", at, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: %v is not a number", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %v is not a boolean", at, v)
+		}
+	}
+	return nil
+}
+
+func isEmptyArray(v interface{}) bool {
+	a, ok := v.([]interface{})
+	return ok && len(a) == 0
+}
+
+// checkResponse checks that the response to method on urlPath is described in the spec.
+func (s apiSpec) checkResponse(method, urlPath string, rec *httptest.ResponseRecorder) error {
+	p, ok := s.path(urlPath)
+	if !ok {
+		return fmt.Errorf("no path for %s", urlPath)
+	}
+	op := s.operation(p, method)
+	if op == nil {
+		return fmt.Errorf("no %s operation for %s", method, p)
+	}
+	responses, _ := op["responses"].(map[string]interface{})
+	resp := s.resolve(responses[strconv.Itoa(rec.Code)])
+	if resp == nil {
+		return fmt.Errorf("%s %s: status %d is not described", method, p, rec.Code)
+	}
+	content, _ := resp["content"].(map[string]interface{})
+	if content == nil {
+		if rec.Body.Len() > 0 {
+			return fmt.Errorf("%s %s: status %d has a body but no content is described", method, p, rec.Code)
+		}
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("%s %s: bad Content-Type: %s", method, p, err)
+	}
+	media := s.resolve(content[mt])
+	if media == nil {
+		return fmt.Errorf("%s %s: %s is not described for status %d", method, p, mt, rec.Code)
+	}
+	if mt != "application/json" {
+		return nil
+	}
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return fmt.Errorf("%s %s: body is not JSON: %s", method, p, err)
+	}
+	return s.validate(s.resolve(media["schema"]), body, method+" "+p)
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	spec := loadSpec(t)
+	handler := setupAPI(t)
+	defer disabledZones.retain(nil)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		noAuth bool
+		code   int
+	}{
+		{"GET", "/hosts/web", "", false, 200},
+		{"GET", "/hosts/web?detail=true", "", false, 200},
+		{"GET", "/hosts/web?format=text", "", false, 200},
+		{"GET", "/hosts/web?format=csv&detail=true", "", false, 200},
+		{"GET", "/hosts/web?format=bogus", "", false, 400},
+		{"GET", "/hosts/web", "", true, 401},
+		{"GET", "/hosts/web-1.example.tld/records", "", false, 200},
+		{"GET", "/hosts/nothere.example.tld/records", "", false, 404},
+		{"GET", "/hosts/web/nc?zone=bogus.tld", "", false, 400},
+		{"GET", "/v2/hosts?q=web&limit=1", "", false, 200},
+		{"GET", "/v2/hosts?q=web&detail=true", "", false, 200},
+		{"GET", "/v2/hosts?q=nothere", "", false, 200},
+		{"GET", "/v2/hosts?q=web&format=ndjson", "", false, 200},
+		{"GET", "/v2/hosts?limit=0", "", false, 400},
+		{"GET", "/v2/hosts?q=web&mode=bogus", "", false, 400},
+		{"POST", "/v2/hosts/batch", `{"queries":[{"q":"web","limit":1},{"q":"web","detail":true},{"q":"web","mode":"bogus"}]}`, false, 200},
+		{"POST", "/v2/hosts/batch", `{"queries":[]}`, false, 400},
+		{"GET", "/v2/complete?q=web", "", false, 200},
+		{"GET", "/v2/complete?limit=0", "", false, 400},
+		{"POST", "/v2/hosts/batch", `{"query":"web"}`, false, 400},
+		{"GET", "/v2/watch?last_event_id=bogus", "", false, 400},
+		{"GET", "/status", "", false, 200},
+		{"GET", "/status?format=text", "", false, 200},
+		{"GET", "/changes?since=1h", "", false, 200},
+		{"GET", "/changes?since=bogus", "", false, 400},
+		{"GET", "/version", "", true, 200},
+		{"GET", "/healthz", "", true, 200},
+		{"GET", "/readyz", "", true, 200},
+		{"GET", "/openapi.json", "", true, 200},
+		{"GET", "/", "", true, 302},
+		{"GET", "/ui/", "", true, 200},
+		{"GET", "/metrics", "", false, 200},
+		{"GET", "/annotations", "", false, 200},
+		{"PUT", "/annotations/web-*", `{"team":"web"}`, false, 200},
+		{"PUT", "/annotations/web-*", `not json`, false, 400},
+		{"POST", "/annotations/web-*/env", `"prod"`, false, 200},
+		{"GET", "/annotations/web-*", "", false, 200},
+		{"GET", "/annotations/web-*/env", "", false, 200},
+		{"GET", "/annotations/web-*/nothere", "", false, 404},
+		{"DELETE", "/annotations/web-*/env", "", false, 204},
+		{"DELETE", "/annotations/nothere", "", false, 404},
+		{"POST", "/annotations/import", "pattern,tag,value\ndb-*,role,db\n", false, 200},
+		{"GET", "/admin/zones", "", false, 200},
+		{"GET", "/admin/zones/example.tld", "", false, 200},
+		{"GET", "/admin/zones/bogus.tld", "", false, 404},
+		{"POST", "/admin/zones/other.tld/disable", "", false, 200},
+		{"POST", "/admin/zones/other.tld/refresh", "", false, 409},
+		{"DELETE", "/admin/zones/other.tld/cache", "", false, 200},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if !tc.noAuth {
+			req.Header.Set("Authorization", "Bearer "+testToken)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.code {
+			t.Errorf("%s %s: got %d, want %d: %s", tc.method, tc.path, rec.Code, tc.code, rec.Body.String())
+			continue
+		}
+		if err := spec.checkResponse(tc.method, req.URL.Path, rec); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestOpenAPINotModified checks that responses with an ETag are answered with 304 when the client has them,
+// and not after the annotations are changed.
+func TestOpenAPINotModified(t *testing.T) {
+	spec := loadSpec(t)
+	handler := setupAPI(t)
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if err := spec.checkResponse("GET", req.URL.Path, rec); err != nil {
+			t.Error(err)
+		}
+		return rec
+	}
+	for _, path := range []string{"/hosts/web", "/hosts/web-1.example.tld/records", "/v2/hosts?q=web"} {
+		first := get(path, "")
+		etag := first.Header().Get("ETag")
+		if first.Code != 200 || etag == "" {
+			t.Errorf("GET %s: got %d with ETag %q", path, first.Code, etag)
+			continue
+		}
+		if rec := get(path, etag); rec.Code != 304 {
+			t.Errorf("GET %s with If-None-Match: got %d, want 304", path, rec.Code)
+		}
+		text := path + "?format=text"
+		if strings.Contains(path, "?") {
+			text = path + "&format=text"
+		}
+		if rec := get(text, etag); rec.Code != 200 {
+			t.Errorf("GET %s with the ETag of JSON: got %d, want 200", text, rec.Code)
+		}
+	}
+
+	etag := get("/hosts/web", "").Header().Get("ETag")
+	if err := annotations.set(map[string]map[string]string{"web-*": {"etag": "test"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	defer annotations.delete("web-*", "etag")
+	if rec := get("/hosts/web", etag); rec.Code != 200 {
+		t.Errorf("GET /hosts/web after annotations changed: got %d, want 200", rec.Code)
+	}
+}
+
+// TestOpenAPIRoutes checks that every route of the server is described, and nothing else.
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	router := setupAPI(t).(*mux.Router)
+
+	// The v1 reload route only does something when the last segment is nc.
+	aliases := map[string]string{"/hosts/{id}/{nc}": "/hosts/{id}/nc"}
+	routes := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		if a, ok := aliases[tpl]; ok {
+			tpl = a
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		for _, m := range methods {
+			routes[m+" "+tpl] = true
+			if spec.operation(tpl, m) == nil {
+				t.Errorf("%s %s is not described", m, tpl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, _ := spec["paths"].(map[string]interface{})
+	for p, item := range paths {
+		for m := range spec.resolve(item) {
+			if m == "parameters" {
+				continue
+			}
+			if !routes[strings.ToUpper(m)+" "+p] {
+				t.Errorf("%s %s is described but not a route", strings.ToUpper(m), p)
+			}
+		}
+	}
+}
+
+// TestOpenAPIRefs checks that all references in the spec resolve.
+func TestOpenAPIRefs(t *testing.T) {
+	spec := loadSpec(t)
+	var walk func(v interface{}, at string)
+	walk = func(v interface{}, at string) {
+		switch t2 := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := t2["$ref"].(string); ok && spec.resolve(t2) == nil {
+				t.Errorf("%s: %s does not resolve", at, ref)
+			}
+			for k, e := range t2 {
+				walk(e, at+"/"+k)
+			}
+		case []interface{}:
+			for i, e := range t2 {
+				walk(e, at+"/"+strconv.Itoa(i))
+			}
+		}
+	}
+	walk(map[string]interface{}(spec), "#")
+}
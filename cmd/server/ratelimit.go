@@ -0,0 +1,212 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// Classes of requests with separate rate limits.
+const (
+	limitSearch = "search"
+	limitStatus = "status"
+	limitReload = "reload"
+)
+
+// limitedRoutes is the class of the routes that are rate limited. Forced reloads are limited as
+// reload in addition to their class, and the admin routes that transfer a zone only as reload.
+var limitedRoutes = map[string]string{
+	"/hosts/{id}":         limitSearch,
+	"/hosts/{id}/records": limitSearch,
+	"/hosts/{id}/{nc}":    limitSearch,
+	"/v2/hosts":           limitSearch,
+	"/v2/hosts/batch":     limitSearch,
+	"/v2/complete":        limitSearch,
+	"/status":             limitStatus,
+	"/changes":            limitStatus,
+	"/metrics":            limitStatus,
+
+	"/admin/zones/{zone}/refresh": limitReload,
+	"/admin/zones/{zone}/enable":  limitReload,
+}
+
+// rejectLogInterval is how often rejected requests from one client are logged.
+const rejectLogInterval = time.Minute
+
+var limiter = rateLimiter{buckets: map[string]*bucket{}, rejected: map[string]uint64{}}
+
+// rateLimiter is a token bucket per class and client.
+type rateLimiter struct {
+	sync.Mutex
+	buckets   map[string]*bucket // buckets is keyed by class and client.
+	rejected  map[string]uint64  // rejected is the number of rejected requests per class.
+	lastSweep time.Time
+}
+
+// bucket is the requests one client may do. It is full with Burst tokens and gets Rate tokens per second.
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	rejected uint64    // rejected is the number of rejected requests not yet logged.
+	logged   time.Time // logged is when rejected requests were last logged.
+}
+
+// limit returns the configured limit of class.
+func limit(config *gethost.Config, class string) gethost.RateLimit {
+	switch class {
+	case limitSearch:
+		return config.SearchLimit
+	case limitStatus:
+		return config.StatusLimit
+	case limitReload:
+		return config.ReloadLimit
+	}
+	return gethost.RateLimit{}
+}
+
+// allow takes a token from the bucket of client in every class, or none if any class has no token.
+// Then it returns false, the class and how long until it has a token.
+func (l *rateLimiter) allow(client string, classes []string, config *gethost.Config, now time.Time) (bool, string, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.sweep(config, now)
+	var take []*bucket
+	for _, class := range classes {
+		rl := limit(config, class)
+		if rl.Rate <= 0 {
+			continue
+		}
+		burst := float64(rl.Burst)
+		key := class + " " + client
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: burst, last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rl.Rate)
+		b.last = now
+		if b.tokens < 1 {
+			l.rejected[class]++
+			b.rejected++
+			if now.Sub(b.logged) >= rejectLogInterval {
+				log.Printf("Rate limited %d %s requests from %s\n", b.rejected, class, client)
+				b.rejected = 0
+				b.logged = now
+			}
+			return false, class, time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+		}
+		take = append(take, b)
+	}
+	for _, b := range take {
+		b.tokens--
+	}
+	return true, "", 0
+}
+
+// charge takes n more tokens from the bucket of client in class, for a request that was allowed but
+// costs more than one token. The bucket may go below zero, and the client then waits until it is refilled.
+func (l *rateLimiter) charge(client string, class string, n int, config *gethost.Config, now time.Time) {
+	rl := limit(config, class)
+	if rl.Rate <= 0 || n <= 0 {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	key := class + " " + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rl.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rl.Burst), b.tokens+now.Sub(b.last).Seconds()*rl.Rate) - float64(n)
+	b.last = now
+}
+
+// sweep forgets clients that have not made requests for a while and whose buckets are full again.
+func (l *rateLimiter) sweep(config *gethost.Config, now time.Time) {
+	if now.Sub(l.lastSweep) < rejectLogInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		rl := limit(config, k[:strings.Index(k, " ")])
+		full := rl.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*rl.Rate >= float64(rl.Burst)
+		if full && now.Sub(b.last) > rejectLogInterval && now.Sub(b.logged) > rejectLogInterval {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// rejectedCounts returns the number of rejected requests per class.
+func (l *rateLimiter) rejectedCounts() map[string]uint64 {
+	l.Lock()
+	defer l.Unlock()
+	ret := map[string]uint64{}
+	for _, c := range []string{limitSearch, limitStatus, limitReload} {
+		ret[c] = l.rejected[c]
+	}
+	return ret
+}
+
+// clientKey is the token name if the request has a named token, and the client IP address otherwise.
+// All clients on Unix domain sockets share one key.
+func clientKey(r *http.Request) string {
+	return clientKeyOf(scopeFrom(r), r.RemoteAddr)
+}
+
+// clientKeyOf is clientKey for a client with scope s at remoteAddr.
+func clientKeyOf(s *scope, remoteAddr string) string {
+	if s != nil && s.name != "" {
+		return "token " + s.name
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if host == "" || host == "@" {
+		return "unix socket"
+	}
+	return host
+}
+
+// forcesReload returns true if the request has the nc flag.
+func forcesReload(r *http.Request) bool {
+	return mux.Vars(r)["nc"] == "nc" || (r.URL.Path == "/v2/hosts" && boolParam(r, "nc"))
+}
+
+// rateLimitMiddleware answers 429 Too Many Requests when a client does more requests than allowed,
+// must be used after authMiddleware to limit by token.
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := ""
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if t, err := cr.GetPathTemplate(); err == nil {
+				class = limitedRoutes[t]
+			}
+		}
+		if class == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		classes := []string{class}
+		if forcesReload(r) {
+			classes = append(classes, limitReload)
+		}
+		if ok, c, wait := limiter.allow(clientKey(r), classes, liveConfig.get(), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, "too many "+c+" requests, retry later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
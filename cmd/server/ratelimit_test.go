@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+func TestRateLimitRefill(t *testing.T) {
+	l := rateLimiter{buckets: map[string]*bucket{}, rejected: map[string]uint64{}}
+	config := &gethost.Config{SearchLimit: gethost.RateLimit{Rate: 2, Burst: 3}}
+	start := time.Now()
+	search := []string{limitSearch}
+
+	tests := []struct {
+		after time.Duration
+		ok    bool
+		wait  time.Duration
+	}{
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 500 * time.Millisecond},
+		{250 * time.Millisecond, false, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		// The bucket is never fuller than Burst.
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, false, 500 * time.Millisecond},
+	}
+	for i, tc := range tests {
+		ok, class, wait := l.allow("10.0.0.1", search, config, start.Add(tc.after))
+		if ok != tc.ok || wait != tc.wait {
+			t.Errorf("request %d after %s: got %v and wait %s, want %v and %s", i, tc.after, ok, wait, tc.ok, tc.wait)
+		}
+		if !ok && class != limitSearch {
+			t.Errorf("request %d: limited by %q, want %q", i, class, limitSearch)
+		}
+	}
+	if got := l.rejectedCounts()[limitSearch]; got != 4 {
+		t.Errorf("got %d rejected, want 4", got)
+	}
+
+	// Other clients have their own buckets, and classes without Rate are not limited.
+	if ok, _, _ := l.allow("10.0.0.2", search, config, start); !ok {
+		t.Error("another client was limited")
+	}
+	for i := 0; i < 10; i++ {
+		if ok, _, _ := l.allow("10.0.0.1", []string{limitStatus}, config, start); !ok {
+			t.Error("status without Rate was limited")
+		}
+	}
+}
+
+// TestRateLimitClasses checks that a request in several classes takes a token from every class,
+// and from none when one of them has no token.
+func TestRateLimitClasses(t *testing.T) {
+	l := rateLimiter{buckets: map[string]*bucket{}, rejected: map[string]uint64{}}
+	config := &gethost.Config{
+		SearchLimit: gethost.RateLimit{Rate: 1, Burst: 3},
+		ReloadLimit: gethost.RateLimit{Rate: 0.1, Burst: 1},
+	}
+	now := time.Now()
+	reload := []string{limitSearch, limitReload}
+
+	if ok, _, _ := l.allow("c", reload, config, now); !ok {
+		t.Fatal("first reload was limited")
+	}
+	ok, class, wait := l.allow("c", reload, config, now)
+	if ok || class != limitReload || wait != 10*time.Second {
+		t.Errorf("second reload: got %v by %q and wait %s, want false by %q and 10s", ok, class, wait, limitReload)
+	}
+	// The rejected reload did not take a search token, so two searches are left.
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := l.allow("c", []string{limitSearch}, config, now); !ok {
+			t.Errorf("search %d was limited", i)
+		}
+	}
+	if ok, class, _ := l.allow("c", []string{limitSearch}, config, now); ok || class != limitSearch {
+		t.Errorf("third search: got %v by %q, want false by %q", ok, class, limitSearch)
+	}
+	// A reload without search tokens takes no reload token.
+	later := now.Add(10 * time.Second)
+	for i := 0; i < 3; i++ {
+		l.allow("c", []string{limitSearch}, config, later)
+	}
+	if ok, class, _ := l.allow("c", reload, config, later); ok || class != limitSearch {
+		t.Errorf("reload without search tokens: got %v by %q, want false by %q", ok, class, limitSearch)
+	}
+	if ok, _, _ := l.allow("c", reload, config, later.Add(time.Second)); !ok {
+		t.Error("reload when both classes have a token was limited")
+	}
+
+	counts := l.rejectedCounts()
+	if counts[limitSearch] != 2 || counts[limitReload] != 1 {
+		t.Errorf("got rejected %v, want 2 search and 1 reload", counts)
+	}
+}
+
+// TestRateLimitCharge checks that a request charged for more tokens than the bucket has makes the
+// client wait until they are refilled, and that the bucket is not forgotten before then.
+func TestRateLimitCharge(t *testing.T) {
+	l := rateLimiter{buckets: map[string]*bucket{}, rejected: map[string]uint64{}}
+	config := &gethost.Config{SearchLimit: gethost.RateLimit{Rate: 1, Burst: 10}}
+	start := time.Now()
+	search := []string{limitSearch}
+
+	if ok, _, _ := l.allow("10.0.0.1", search, config, start); !ok {
+		t.Fatal("first request was limited")
+	}
+	l.charge("10.0.0.1", limitSearch, 99, config, start)
+	if ok, _, wait := l.allow("10.0.0.1", search, config, start); ok || wait != 91*time.Second {
+		t.Errorf("after a charge of 100: got %v and wait %s, want false and 1m31s", ok, wait)
+	}
+	if ok, _, _ := l.allow("10.0.0.1", search, config, start.Add(90*time.Second)); ok {
+		t.Error("request before the bucket was refilled was allowed")
+	}
+	if ok, _, _ := l.allow("10.0.0.1", search, config, start.Add(91*time.Second)); !ok {
+		t.Error("request after the bucket was refilled was limited")
+	}
+
+	// Unlimited classes are not charged.
+	l.charge("10.0.0.1", limitStatus, 99, config, start)
+	if ok, _, _ := l.allow("10.0.0.1", []string{limitStatus}, config, start); !ok {
+		t.Error("status without Rate was limited after a charge")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/miekg/dns"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// hostDetail is the detailed information about one name in the cache.
+type hostDetail struct {
+	Name      string            `json:"name"`
+	Zone      string            `json:"zone"`
+	Serial    uint32            `json:"serial"` // Serial is the SOA serial of the zone when the name was loaded.
+	Types     []string          `json:"types"`
+	Addresses []string          `json:"addresses"`
+	CNAME     string            `json:"cname,omitempty"`
+	Records   []recordDetail    `json:"records"`
+	Tags      map[string]string `json:"tags,omitempty"` // Tags is user supplied annotations, see annotationStore.
+
+	rrs []dns.RR
+}
+
+// recordDetail is one resource record of a name.
+type recordDetail struct {
+	Type  string `json:"type"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// details returns hostDetail for the names that still are in the cache.
+func (c *cache) details(names []string) []hostDetail {
+	ret := []hostDetail{}
+	c.RLock()
+	defer c.RUnlock()
+	for _, name := range names {
+		if d, ok := c.detail(name); ok {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// detail returns hostDetail for name, the caller must hold the lock. A name that is in several zones
+// is reported from the longest of them, and of those the first in sort order.
+func (c *cache) detail(name string) (hostDetail, bool) {
+	zone := ""
+	for z, d := range c.zones {
+		if _, ok := d.RR[name]; !ok {
+			continue
+		}
+		if zone == "" || len(z) > len(zone) || (len(z) == len(zone) && z < zone) {
+			zone = z
+		}
+	}
+	if zone == "" {
+		return hostDetail{}, false
+	}
+	z := c.zones[zone]
+	rrs := z.RR[name]
+	d := hostDetail{
+		Name:      name,
+		Zone:      zone,
+		Types:     []string{},
+		Addresses: []string{},
+		Records:   []recordDetail{},
+		rrs:       rrs,
+	}
+	if z.SOA != nil {
+		d.Serial = z.SOA.Serial
+	}
+	types := map[string]bool{}
+	for _, rr := range rrs {
+		h := rr.Header()
+		t := dns.TypeToString[h.Rrtype]
+		types[t] = true
+		switch v := rr.(type) {
+		case *dns.A:
+			d.Addresses = append(d.Addresses, v.A.String())
+		case *dns.AAAA:
+			d.Addresses = append(d.Addresses, v.AAAA.String())
+		case *dns.CNAME:
+			d.CNAME = strings.TrimRight(v.Target, ".")
+		}
+		d.Records = append(d.Records, recordDetail{
+			Type:  t,
+			TTL:   h.Ttl,
+			Value: strings.TrimPrefix(rr.String(), h.String()),
+		})
+	}
+	for t := range types {
+		d.Types = append(d.Types, t)
+	}
+	sort.Strings(d.Types)
+	sort.Strings(d.Addresses)
+	return d, true
+}
+
+// boolParam returns true if the query parameter key is set to a true value, e.g. "1" or "true".
+func boolParam(r *http.Request, key string) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get(key))
+	return b
+}
+
+func httpRecords(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	span := tracer.StartSpan("httpRecords", ext.RPCServerOption(spanCtx))
+	defer span.Finish()
+
+	format, err := negotiate(r)
+	if err != nil {
+		formatError(w, r, err)
+		return
+	}
+	name := strings.TrimRight(mux.Vars(r)["id"], ".")
+
+	dnsRR.RLock()
+	d, ok := dnsRR.detail(name)
+	dnsRR.RUnlock()
+	if !ok || !scopeFrom(r).visible(d.Zone, d.Name) {
+		writeError(w, "No records for "+name, http.StatusNotFound)
+		return
+	}
+	d.Tags = annotations.tagsFor(d.Name)
+
+	if format == formatJSON {
+		writeJSON(w, d)
+		return
+	}
+	writeHostDetails(w, format, []hostDetail{d})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// TestDetailZone checks that a name in several zones is always reported from the same zone.
+func TestDetailZone(t *testing.T) {
+	c := cache{zones: map[string]gethost.SOAwithRR{
+		"example.tld.":     testZone(t, "example.tld.", 1, "web.sub.example.tld. 300 IN A 10.0.0.1", "box.example.tld. 300 IN A 10.0.0.2"),
+		"sub.example.tld.": testZone(t, "sub.example.tld.", 2, "web.sub.example.tld. 300 IN A 10.0.0.1"),
+		"aa.tld.":          testZone(t, "aa.tld.", 3, "box.example.tld. 300 IN A 10.0.0.2"),
+		"zz.tld.":          testZone(t, "zz.tld.", 4, "box.example.tld. 300 IN A 10.0.0.2"),
+	}}
+
+	tests := []struct {
+		name string
+		zone string
+	}{
+		{"web.sub.example.tld", "sub.example.tld."},
+		{"box.example.tld", "example.tld."},
+	}
+	for _, tc := range tests {
+		// The zones are a map, so look many times to see that the order does not matter.
+		for i := 0; i < 20; i++ {
+			d, ok := c.detail(tc.name)
+			if !ok || d.Zone != tc.zone {
+				t.Fatalf("detail(%q): got zone %q and %v, want %q", tc.name, d.Zone, ok, tc.zone)
+			}
+		}
+	}
+	if _, ok := c.detail("nothing.example.tld"); ok {
+		t.Error("detail of a missing name was found")
+	}
+
+	// Zones of the same length are chosen in sort order.
+	delete(c.zones, "example.tld.")
+	for i := 0; i < 20; i++ {
+		if d, _ := c.detail("box.example.tld"); d.Zone != "aa.tld." || d.Serial != 3 {
+			t.Fatalf("detail of a name in aa.tld. and zz.tld.: got zone %q serial %d, want aa.tld. and 3", d.Zone, d.Serial)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// Outcomes of a forced refresh, sent in the X-Cache-Refresh header.
+const (
+	refreshDone    = "refreshed" // refreshDone is a refresh done for this request.
+	refreshShared  = "shared"    // refreshShared is a refresh that was already in progress.
+	refreshSkipped = "skipped"   // refreshSkipped is when the zones were transferred less than MinRefreshInterval ago.
+	refreshFailed  = "failed"    // refreshFailed is a refresh that failed, the old data is kept.
+)
+
+var refreshes = refresher{calls: map[*refreshCall]bool{}, update: updateDNS}
+
+// refresher coalesces updates of the cache, so concurrent requests share one update, and runs
+// one update at a time, so an older transfer never replaces a newer one.
+type refresher struct {
+	sync.Mutex
+	calls  map[*refreshCall]bool // calls is the updates in progress or waiting to run.
+	run    sync.Mutex            // run is held by the update that runs.
+	update func(ctx context.Context, config *gethost.Config, zones ...string) error
+	shared func() // shared is called, if set, when a call shares an update in progress.
+}
+
+// refreshCall is one forced update of the cache.
+type refreshCall struct {
+	zones map[string]bool // zones is the zones updated, nil is all zones.
+	done  chan struct{}   // done is closed when err is set.
+	err   error
+}
+
+// covers returns true if the call updates all of zones, where nil is all zones.
+func (c *refreshCall) covers(zones []string) bool {
+	if c.zones == nil {
+		return true
+	}
+	if zones == nil {
+		return false
+	}
+	for _, z := range zones {
+		if !c.zones[z] {
+			return false
+		}
+	}
+	return true
+}
+
+// force updates zones, or all enabled zones if zones is nil. If an update of the zones already is in progress
+// or waiting to run its result is shared. If all zones were transferred less than interval ago nothing is done,
+// and the time until a refresh is allowed is returned. The scheduled updates also use force, with no interval.
+func (f *refresher) force(ctx context.Context, config *gethost.Config, zones []string, interval time.Duration) (string, time.Duration, error) {
+	f.Lock()
+	for c := range f.calls {
+		if c.covers(zones) {
+			f.Unlock()
+			if f.shared != nil {
+				f.shared()
+			}
+			<-c.done
+			return refreshShared, 0, c.err
+		}
+	}
+
+	want := zones
+	if want == nil {
+		want = disabledZones.enabled(config.Zones)
+	}
+	if wait := refreshWait(want, interval, time.Now()); wait > 0 {
+		f.Unlock()
+		return refreshSkipped, wait, nil
+	}
+
+	c := &refreshCall{done: make(chan struct{})}
+	if zones != nil {
+		c.zones = map[string]bool{}
+		for _, z := range zones {
+			c.zones[z] = true
+		}
+	}
+	f.calls[c] = true
+	f.Unlock()
+
+	f.run.Lock()
+	c.err = f.update(ctx, config, zones...)
+	f.run.Unlock()
+	f.Lock()
+	delete(f.calls, c)
+	f.Unlock()
+	close(c.done)
+
+	if c.err != nil {
+		return refreshFailed, 0, c.err
+	}
+	return refreshDone, 0, nil
+}
+
+// locked runs fn while no update runs, for changes of the cache that must not be mixed with an update.
+func (f *refresher) locked(fn func()) {
+	f.run.Lock()
+	defer f.run.Unlock()
+	fn()
+}
+
+// refreshWait returns how long until the zone with the oldest transfer attempt may be transferred again.
+func refreshWait(zones []string, interval time.Duration, now time.Time) time.Duration {
+	if interval <= 0 || len(zones) == 0 {
+		return 0
+	}
+	metrics.Lock()
+	defer metrics.Unlock()
+	var oldest time.Time
+	for _, z := range zones {
+		ts, ok := metrics.transfers[z]
+		if !ok {
+			return 0
+		}
+		if oldest.IsZero() || ts.LastAttempt.Before(oldest) {
+			oldest = ts.LastAttempt
+		}
+	}
+	return interval - now.Sub(oldest)
+}
+
+// refreshZones returns the configured zones that match zones, nil if zones is empty.
+// Tokens restricted to some zones may only refresh those, and disabled zones can not be refreshed.
+func refreshZones(config *gethost.Config, zones []string, s *scope) ([]string, int, error) {
+	if len(zones) == 0 {
+		if !s.all() {
+			return nil, http.StatusForbidden, errors.New("token may only refresh its own zones, use the zone parameter")
+		}
+		return nil, 0, nil
+	}
+	ret := []string{}
+	for _, z := range zones {
+		found := configuredZone(config, z)
+		if found == "" {
+			return nil, http.StatusBadRequest, errors.New("unknown zone " + z)
+		}
+		if !s.zone(found) {
+			return nil, http.StatusForbidden, errors.New("token may not refresh zone " + found)
+		}
+		if disabledZones.disabled(found) {
+			return nil, http.StatusConflict, errors.New("zone " + found + " is disabled")
+		}
+		ret = append(ret, found)
+	}
+	sort.Strings(ret)
+	return ret, 0, nil
+}
+
+// configuredZone returns the zone in the configuration that is zone, or "" if there is none.
+// The trailing dot may be left out and case does not matter.
+func configuredZone(config *gethost.Config, zone string) string {
+	for _, c := range config.Zones {
+		if strings.EqualFold(c, dns.Fqdn(zone)) {
+			return c
+		}
+	}
+	return ""
+}
+
+// forceRefresh does the nc flag of a request, with the zone parameters limiting what is refreshed.
+// It writes the outcome to the X-Cache-Refresh header, and returns false if it wrote an error response.
+func forceRefresh(ctx context.Context, w http.ResponseWriter, r *http.Request, config *gethost.Config) bool {
+	zones, code, err := refreshZones(config, r.URL.Query()["zone"], scopeFrom(r))
+	if err != nil {
+		writeError(w, err.Error(), code)
+		return false
+	}
+	outcome, wait, err := refreshes.force(ctx, config, zones, time.Duration(config.MinRefreshInterval)*time.Second)
+	w.Header().Set("X-Cache-Refresh", outcome)
+	switch outcome {
+	case refreshSkipped:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		if config.Verbose == true {
+			log.Printf("Skipped forced refresh of %v, allowed again in %s\n", zones, wait)
+		}
+	case refreshFailed:
+		log.Printf("Forced refresh of %v failed: %s\n", zones, err)
+	}
+	return true
+}
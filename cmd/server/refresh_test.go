@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// TestRefresherSerializes checks that updates never run at the same time, and that a refresh of a zone
+// shares an update of all zones that is in progress.
+func TestRefresherSerializes(t *testing.T) {
+	var running, maxRunning, updates int32
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	shared := make(chan struct{}, 10)
+	f := refresher{calls: map[*refreshCall]bool{}, shared: func() { shared <- struct{}{} }}
+	f.update = func(ctx context.Context, config *gethost.Config, zones ...string) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		atomic.AddInt32(&updates, 1)
+		started <- struct{}{}
+		<-release
+		atomic.AddInt32(&running, -1)
+		return nil
+	}
+	config := &gethost.Config{Zones: []string{"example.tld.", "other.tld."}}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	outcomes := make(chan string, 10)
+	run := func(zones []string) {
+		defer wg.Done()
+		outcome, _, err := f.force(ctx, config, zones, 0)
+		if err != nil {
+			t.Error(err)
+		}
+		outcomes <- outcome
+	}
+
+	// A scheduled update of all zones, and then forced refreshes of one zone while it runs.
+	wg.Add(1)
+	go run(nil)
+	<-started
+	wg.Add(2)
+	go run([]string{"example.tld."})
+	go run([]string{"other.tld."})
+	<-shared
+	<-shared
+	release <- struct{}{}
+	wg.Wait()
+
+	got := map[string]int{}
+	for i := 0; i < 3; i++ {
+		got[<-outcomes]++
+	}
+	if n := atomic.LoadInt32(&updates); got[refreshShared] != 2 || got[refreshDone] != 1 || n != 1 {
+		t.Errorf("got outcomes %v and %d updates, want 2 shared, 1 refreshed and 1 update", got, n)
+	}
+
+	// A refresh of another zone waits for the one in progress, and then runs its own update.
+	atomic.StoreInt32(&updates, 0)
+	wg.Add(2)
+	go run([]string{"example.tld."})
+	<-started
+	go run([]string{"other.tld."})
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	wg.Wait()
+	for i := 0; i < 2; i++ {
+		if o := <-outcomes; o != refreshDone {
+			t.Errorf("got outcome %s, want %s", o, refreshDone)
+		}
+	}
+	if n := atomic.LoadInt32(&updates); n != 2 {
+		t.Errorf("got %d updates, want 2", n)
+	}
+	if n := atomic.LoadInt32(&maxRunning); n != 1 {
+		t.Errorf("%d updates ran at the same time, want 1", n)
+	}
+}
+
+// TestRefresherLocked checks that locked waits for the update that runs.
+func TestRefresherLocked(t *testing.T) {
+	var finished int32
+	started, release := make(chan struct{}), make(chan struct{})
+	f := refresher{calls: map[*refreshCall]bool{}}
+	f.update = func(ctx context.Context, config *gethost.Config, zones ...string) error {
+		close(started)
+		<-release
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	}
+	config := &gethost.Config{Zones: []string{"example.tld."}}
+
+	done := make(chan struct{})
+	go func() {
+		f.force(context.Background(), config, nil, 0)
+		close(done)
+	}()
+	<-started
+	ran := make(chan struct{})
+	go f.locked(func() {
+		if atomic.LoadInt32(&finished) == 0 {
+			t.Error("locked ran while an update was running")
+		}
+		close(ran)
+	})
+	close(release)
+	<-ran
+	<-done
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// configPollInterval is how often the configuration file is checked for changes.
+const configPollInterval = 5 * time.Second
+
+var liveConfig = configHolder{reloaded: make(chan struct{}, 1)}
+
+// reloading is held by reloadConfig, that is called both on SIGHUP and when the file changes.
+var reloading sync.Mutex
+
+// configHolder holds the configuration in use, that is replaced when the configuration is reloaded.
+type configHolder struct {
+	sync.RWMutex
+	config   *gethost.Config
+	file     string        // file is the configuration file.
+	verbose  bool          // verbose is the -verbose flag, that wins over the configuration file.
+	modTime  time.Time     // modTime is the modification time of file when it was read.
+	reloaded chan struct{} // reloaded wakes up schedUpdate after a reload.
+}
+
+// get returns the configuration in use.
+func (h *configHolder) get() *gethost.Config {
+	h.RLock()
+	defer h.RUnlock()
+	return h.config
+}
+
+func (h *configHolder) set(config *gethost.Config, modTime time.Time) {
+	h.Lock()
+	h.config = config
+	h.modTime = modTime
+	h.Unlock()
+}
+
+// reloadConfig reads the configuration file again and applies it while the cache keeps serving.
+// If the new configuration is invalid the error is returned and the old configuration is kept.
+// The settings in keepStartSettings are only read at start, but changed certificates are loaded.
+func reloadConfig() error {
+	reloading.Lock()
+	defer reloading.Unlock()
+	old := liveConfig.get()
+	fi, err := os.Stat(liveConfig.file)
+	if err != nil {
+		return err
+	}
+	config, err := gethost.NewConfig(&liveConfig.file)
+	if err != nil {
+		return err
+	}
+	if liveConfig.verbose {
+		config.Verbose = true
+	}
+	if keepStartSettings(config, old) {
+		log.Println("ServerPort, GRPCPort, Listen, GRPCListen, sockets, Tracing and TLS files can not be changed without restart, keeping the old values.")
+	}
+	if config.AnnotationFile != old.AnnotationFile {
+		if err := annotations.switchFile(config.AnnotationFile); err != nil {
+			return err
+		}
+	}
+
+	changes.setMax(config.ChangeLogSize)
+	refreshes.locked(func() {
+		watchers.publish(recordChanges(config, dnsRR.retainZones(config.Zones))...)
+		disabledZones.retain(config.Zones)
+		liveConfig.set(config, fi.ModTime())
+	})
+	log.Printf("Reloaded configuration from %s, zones: %v\n", liveConfig.file, config.Zones)
+
+	select {
+	case liveConfig.reloaded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// keepStartSettings copies the settings that are only read at start from old to config,
+// and returns true if any of them differed.
+func keepStartSettings(config, old *gethost.Config) bool {
+	changed := config.ServerPort != old.ServerPort || config.GRPCPort != old.GRPCPort ||
+		!reflect.DeepEqual(config.Listen, old.Listen) || !reflect.DeepEqual(config.GRPCListen, old.GRPCListen) ||
+		config.SocketMode != old.SocketMode || config.SocketOwner != old.SocketOwner || config.Tracing != old.Tracing ||
+		config.TLSCertFile != old.TLSCertFile || config.TLSKeyFile != old.TLSKeyFile || config.TLSCAFile != old.TLSCAFile
+	config.ServerPort = old.ServerPort
+	config.GRPCPort = old.GRPCPort
+	config.Listen = old.Listen
+	config.GRPCListen = old.GRPCListen
+	config.SocketMode = old.SocketMode
+	config.SocketOwner = old.SocketOwner
+	config.Tracing = old.Tracing
+	config.TLSCertFile = old.TLSCertFile
+	config.TLSKeyFile = old.TLSKeyFile
+	config.TLSCAFile = old.TLSCAFile
+	return changed
+}
+
+// watchConfig reloads the configuration when the modification time of the configuration file changes.
+func watchConfig(interval time.Duration) {
+	for range time.Tick(interval) {
+		fi, err := os.Stat(liveConfig.file)
+		if err != nil {
+			log.Println("Could not check configuration file:", err)
+			continue
+		}
+		liveConfig.RLock()
+		changed := !fi.ModTime().Equal(liveConfig.modTime)
+		liveConfig.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := reloadConfig(); err != nil {
+			log.Println("Rejected new configuration, keeping the old one:", err)
+			// Do not try the same file again until it changes.
+			liveConfig.Lock()
+			liveConfig.modTime = fi.ModTime()
+			liveConfig.Unlock()
+		}
+	}
+}
+
+// waitForSignals reloads the configuration on SIGHUP, and shuts down servers on SIGTERM or SIGINT.
+// Requests in flight are given timeout to finish before waitForSignals returns.
+func waitForSignals(timeout time.Duration, servers ...*http.Server) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	for sig := range sigs {
+		if sig == syscall.SIGHUP {
+			if err := reloadConfig(); err != nil {
+				log.Println("Rejected new configuration, keeping the old one:", err)
+			}
+			continue
+		}
+
+		log.Printf("Got %s, shutting down, waiting at most %s for requests to finish.\n", sig, timeout)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		var wg sync.WaitGroup
+		for _, srv := range servers {
+			wg.Add(1)
+			go func(srv *http.Server) {
+				defer wg.Done()
+				if err := srv.Shutdown(ctx); err != nil {
+					log.Println("Could not shut down gracefully:", err)
+				}
+			}(srv)
+		}
+		wg.Wait()
+		cancel()
+		return
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,8 +12,8 @@ import (
 	"net/http"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -35,12 +36,26 @@ func init() {
 func main() {
 	verbose := flag.Bool("verbose", false, "Print reload and responses to questions to standard out")
 	configFile := flag.String("configfile", "", "Configuation file")
+	hashtoken := flag.Bool("hashtoken", false, "Print the hash of the token on standard in, for Tokens in the configuration file, and exit")
 	goversionflag.PrintVersionAndExit()
 
+	if *hashtoken == true {
+		token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			log.Fatalln(err)
+		}
+		fmt.Println(hashToken(strings.TrimSpace(token)))
+		return
+	}
+
 	if *configFile == "" {
 		log.Fatalln("Need configuration file.")
 	}
 
+	fi, err := os.Stat(*configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	config, err := gethost.NewConfig(configFile)
 	if err != nil {
 		log.Println("Got error when parsing configuration file: " + err.Error())
@@ -61,69 +76,125 @@ func main() {
 	}
 	opentracing.SetGlobalTracer(tracer)
 
-	go schedUpdate(tracer, config)
-	handleRequests(config)
+	changes.max = config.ChangeLogSize
+	annotations.file = config.AnnotationFile
+	if err := annotations.load(); err != nil {
+		log.Fatalln("Could not load annotations:", err)
+	}
+
+	liveConfig.file = *configFile
+	liveConfig.verbose = *verbose
+	liveConfig.set(config, fi.ModTime())
+
+	go schedUpdate(tracer)
+	go watchConfig(configPollInterval)
+
+	srv := handleRequests(config)
+	srv.TLSConfig, err = serverTLSConfig(config)
+	if err != nil {
+		log.Fatalln("Could not configure TLS:", err)
+	}
+	servers := []*http.Server{srv}
+	if err := serve("server", srv, config.Listen, config.ServerPort, config); err != nil {
+		log.Fatalln(err)
+	}
+	if config.GRPCPort != 0 || len(config.GRPCListen) > 0 {
+		grpcSrv := grpcServer(srv.TLSConfig)
+		servers = append(servers, grpcSrv)
+		if err := serve("gRPC server", grpcSrv, config.GRPCListen, config.GRPCPort, config); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	waitForSignals(time.Duration(config.ShutdownTimeout)*time.Second, servers...)
 }
 
-func schedUpdate(tracer opentracing.Tracer, config *gethost.Config) {
-	log.Printf("Starting scheduled update of cache every %v seconds.\n", config.TTL)
+// schedUpdate updates the cache every TTL seconds, and directly when the configuration is reloaded.
+func schedUpdate(tracer opentracing.Tracer) {
+	log.Printf("Starting scheduled update of cache every %v seconds.\n", liveConfig.get().TTL)
 	for {
+		config := liveConfig.get()
 		if config.Verbose == true {
 			log.Println("Scheduled update in progress.")
 		}
 		span := tracer.StartSpan("schedUpdate")
 		ctx := opentracing.ContextWithSpan(context.Background(), span)
 
-		updateDNS(ctx, config)
+		refreshes.force(ctx, config, nil, 0)
 		span.Finish()
-		time.Sleep(time.Duration(config.TTL) * time.Second)
+		select {
+		case <-time.After(time.Duration(config.TTL) * time.Second):
+		case <-liveConfig.reloaded:
+		}
 	}
 }
 
-func updateDNS(ctx context.Context, config *gethost.Config) {
+// updateDNS updates zones in the cache, or all enabled zones if no zones are given.
+// Nothing is updated if the transfer of any zone fails. Zones disabled during the transfer are not updated.
+func updateDNS(ctx context.Context, config *gethost.Config, zones ...string) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "updateDNS")
 	defer span.Finish()
+	atomic.AddInt32(&metrics.refreshing, 1)
+	defer atomic.AddInt32(&metrics.refreshing, -1)
 
-	dnsRRdataNew, soas, err := buildDNS(ctx, config)
+	partial := len(zones) > 0
+	if !partial {
+		zones = disabledZones.enabled(config.Zones)
+	}
+	built, err := buildDNS(ctx, config, zones)
+	metrics.refreshed(err)
 	if err != nil {
 		log.Printf("Could not build DNS; %s", err)
-		return
+		return err
+	}
+	for z := range built {
+		if disabledZones.disabled(z) {
+			delete(built, z)
+		}
 	}
-	dnsRR.Lock()
-	dnsRR.data = dnsRRdataNew
-	dnsRR.age = time.Now()
-	dnsRR.soas = soas
-	dnsRR.Unlock()
 
+	now := time.Now()
+	sets := dnsRR.update(built, partial, now)
+	watchers.publish(append(recordChanges(config, sets), refreshEvents(built, now)...)...)
+	return nil
+}
+
+// recordChanges adds sets to the change log and fires the hooks for them, and returns their events.
+func recordChanges(config *gethost.Config, sets []changeSet) []event {
+	events := []event{}
+	for _, cs := range sets {
+		cs = changes.add(cs)
+		events = append(events, changeEvents(cs)...)
+		hooks.fire(config, cs)
+		if config.Verbose == true {
+			log.Printf("Zone %s changed, serial %d: %d added, %d removed, %d changed\n",
+				cs.Zone, cs.Serial, len(cs.Added), len(cs.Removed), len(cs.Changed))
+		}
+	}
+	return events
 }
 
-func buildDNS(ctx context.Context, config *gethost.Config) (map[string][]dns.RR, []dns.SOA, error) {
+// buildDNS does AXFR for zones, and returns the result per zone.
+func buildDNS(ctx context.Context, config *gethost.Config, zones []string) (map[string]gethost.SOAwithRR, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "buildDNS")
 	defer span.Finish()
-	var (
-		gotErr []error
-		soas   []dns.SOA
-	)
+	var gotErr []error
 
-	zones := gethost.Zones(config)
 	c := make(chan gethost.GetRRforZoneResult)
 	defer close(c)
 
-	for _, s := range zones {
-		z := s.Header().Name
+	for _, z := range zones {
 		go gethost.GetRRforZone(ctx, z, "", c, config)
 	}
 
-	dnsRRdataNew := map[string][]dns.RR{}
+	zonesNew := map[string]gethost.SOAwithRR{}
 	for range zones {
 		m := <-c
+		metrics.transfer(m, time.Now())
 		if m.Err != nil {
 			gotErr = append(gotErr, m.Err)
+			watchers.publish(event{Type: eventZoneFailed, Time: time.Now(), Zone: m.Zone, Error: m.Err.Error()})
 		} else {
-			soas = append(soas, *m.SOA.SOA)
-			for k, v := range m.SOA.RR {
-				dnsRRdataNew[k] = v
-			}
+			zonesNew[m.Zone] = m.SOA
 		}
 	}
 	if gotErr != nil {
@@ -131,68 +202,132 @@ func buildDNS(ctx context.Context, config *gethost.Config) (map[string][]dns.RR,
 		for _, v := range gotErr {
 			ret = ret + " " + v.Error()
 		}
-		return nil, nil, errors.New("Could not build cache, at least one error: " + ret)
+		return nil, errors.New("Could not build cache, at least one error: " + ret)
 	}
-	return dnsRRdataNew, soas, nil
+	return zonesNew, nil
 }
 
-func handleRequests(config *gethost.Config) {
+// mergeZones returns the records of all zones in one map, and the SOA of every zone.
+func mergeZones(zones map[string]gethost.SOAwithRR) (map[string][]dns.RR, []dns.SOA) {
+	var soas []dns.SOA
+	data := map[string][]dns.RR{}
+	for _, z := range zones {
+		soas = append(soas, *z.SOA)
+		for k, v := range z.RR {
+			data[k] = v
+		}
+	}
+	return data, soas
+}
+
+func handleRequests(config *gethost.Config) *http.Server {
 	myRouter := mux.NewRouter().StrictSlash(true)
-	myRouter.HandleFunc("/hosts/{id}", wrapper(config, httpResponse))
-	myRouter.HandleFunc("/hosts/{id}/{nc}", wrapper(config, httpResponse))
+	myRouter.HandleFunc("/hosts/{id}", requireReady(withETag(wrapper(httpResponse))))
+	myRouter.HandleFunc("/hosts/{id}/records", requireReady(withETag(wrapper(httpRecords))))
+	myRouter.HandleFunc("/hosts/{id}/{nc}", requireReady(withETag(wrapper(httpResponse))))
+	myRouter.HandleFunc("/v2/hosts", requireReady(withETag(wrapper(httpHostsV2))))
+	myRouter.HandleFunc("/v2/hosts/batch", requireReady(wrapper(httpHostsBatch))).Methods("POST")
+	myRouter.HandleFunc("/v2/complete", requireReady(withETag(wrapper(httpComplete))))
+	myRouter.HandleFunc("/v2/watch", wrapper(httpWatch))
 	myRouter.HandleFunc("/version", httpVersion)
-	myRouter.HandleFunc("/status", wrapper(config, httpStatus))
-	addr := ":" + strconv.Itoa(config.ServerPort)
-	log.Println("Staring server on", addr)
-	log.Fatal(http.ListenAndServe(addr, myRouter))
+	myRouter.HandleFunc("/openapi.json", httpOpenAPI)
+	myRouter.HandleFunc("/", httpRoot)
+	myRouter.HandleFunc("/ui/", httpUI)
+	myRouter.HandleFunc("/metrics", requireAll(wrapper(httpMetrics)))
+	myRouter.HandleFunc("/healthz", httpHealthz)
+	myRouter.HandleFunc("/readyz", httpReadyz)
+	myRouter.HandleFunc("/status", wrapper(httpStatus))
+	myRouter.HandleFunc("/changes", wrapper(httpChanges))
+	myRouter.HandleFunc("/annotations", requireAll(wrapper(httpAnnotations))).Methods("GET")
+	myRouter.HandleFunc("/annotations/import", requireAll(wrapper(httpAnnotationsImport))).Methods("POST")
+	myRouter.HandleFunc("/annotations/{pattern}", requireAll(wrapper(httpAnnotations))).Methods("GET", "PUT", "POST", "DELETE")
+	myRouter.HandleFunc("/annotations/{pattern}/{tag}", requireAll(wrapper(httpAnnotations))).Methods("GET", "PUT", "POST", "DELETE")
+	myRouter.HandleFunc("/admin/zones", requireAdmin(wrapper(httpAdminZones))).Methods("GET")
+	myRouter.HandleFunc("/admin/zones/{zone}", requireAdmin(wrapper(httpAdminZone))).Methods("GET")
+	myRouter.HandleFunc("/admin/zones/{zone}/refresh", requireAdmin(wrapper(httpAdminRefresh))).Methods("POST")
+	myRouter.HandleFunc("/admin/zones/{zone}/disable", requireAdmin(wrapper(httpAdminDisable))).Methods("POST")
+	myRouter.HandleFunc("/admin/zones/{zone}/enable", requireAdmin(wrapper(httpAdminEnable))).Methods("POST")
+	myRouter.HandleFunc("/admin/zones/{zone}/cache", requireAdmin(wrapper(httpAdminDrop))).Methods("DELETE")
+	myRouter.Use(metricsMiddleware, gzipMiddleware, authMiddleware, rateLimitMiddleware)
+	srv := &http.Server{
+		Handler: myRouter,
+	}
+	srv.RegisterOnShutdown(watchers.closeAll)
+	return srv
 }
 
-func wrapper(config *gethost.Config, handler func(w http.ResponseWriter, r *http.Request, config *gethost.Config)) func(w http.ResponseWriter, r *http.Request) {
+// wrapper calls handler with the configuration in use.
+func wrapper(handler func(w http.ResponseWriter, r *http.Request, config *gethost.Config)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		handler(w, r, config)
+		handler(w, r, liveConfig.get())
 	}
 }
 
+// writeJSON writes v as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error:", err)
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, string(j))
+}
+
+// errorResponse is the body of all error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes msg as a JSON error response with status code.
+func writeError(w http.ResponseWriter, msg string, code int) {
+	j, _ := json.Marshal(errorResponse{Error: msg})
+	w.Header().Del("ETag")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	fmt.Fprint(w, string(j))
+}
+
 func httpResponse(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
 	spanCtx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
 	span := tracer.StartSpan("httpResponse", ext.RPCServerOption(spanCtx))
 	ctx := opentracing.ContextWithSpan(context.Background(), span)
 	defer span.Finish()
 
+	format, err := negotiate(r)
+	if err != nil {
+		formatError(w, r, err)
+		return
+	}
+
 	vars := mux.Vars(r)
 	hostToGet := vars["id"]
 	noCache := vars["nc"]
 
 	if noCache == "nc" {
 		log.Println("got nc flag")
-		updateDNS(ctx, config)
-	}
-
-	hostnames := []string{}
-
-	dnsRR.RLock()
-	for hostname := range dnsRR.data { // TODO, method on cache struct?
-		if strings.Contains(hostname, hostToGet) {
-			hostnames = append(hostnames, hostname)
+		if !forceRefresh(ctx, w, r, config) {
+			return
 		}
 	}
-	dnsRR.RUnlock()
-	sort.Strings(hostnames)
 
-	dnsRR.Lock()
-	dnsRR.APIhits++
-	dnsRR.Unlock()
+	hostnames, _ := dnsRR.find(hostQuery{Query: hostToGet, Tags: parseTagFilters(r.URL.Query()["tag"]), Scope: scopeFrom(r)})
 
-	j, err := json.Marshal(hostnames)
-	if err != nil {
-		log.Println("Error:", err)
-		os.Exit(1)
-	}
+	atomic.AddInt64(&dnsRR.APIhits, 1)
 
 	if config.Verbose == true {
-		log.Println("Send match for " + hostToGet + ": " + string(j))
+		log.Printf("Send match for %s: %v\n", hostToGet, hostnames)
 	}
-	fmt.Fprintf(w, string(j))
+
+	if boolParam(r, "detail") || boolParam(r, "zonefile") {
+		details := dnsRR.details(hostnames)
+		annotations.annotate(details)
+		writeHostDetails(w, format, details)
+		return
+	}
+	writeNames(w, format, hostnames)
 }
 
 func httpVersion(w http.ResponseWriter, r *http.Request) {
@@ -207,10 +342,10 @@ func httpVersion(w http.ResponseWriter, r *http.Request) {
 	}
 	sort.Strings(buildSlice)
 	for _, v := range buildSlice {
-		fmt.Fprintf(w, v)
+		fmt.Fprint(w, v)
 	}
 	if missingBuildInfo {
-		fmt.Fprintf(w, `Do not have complete buildinfo, see documentaion:
+		fmt.Fprint(w, `Do not have complete buildinfo, see documentaion:
 https://github.com/stockholmuniversity/goversionflag
 https://godoc.org/github.com/stockholmuniversity/goversionflag
 `)
@@ -223,39 +358,65 @@ func httpStatus(w http.ResponseWriter, r *http.Request, config *gethost.Config)
 	span := tracer.StartSpan("httpStatus", ext.RPCServerOption(spanCtx))
 	defer span.Finish()
 
+	format, err := negotiate(r)
+	if err != nil {
+		formatError(w, r, err)
+		return
+	}
+
 	type zoneSerial struct {
 		cache  int    // TODO: Not yet implemented
 		Serial uint32 `json:"serial"`
+		Age    string `json:"age"` // Age is how long ago the zone was loaded.
 	}
 
+	sc := scopeFrom(r)
 	ret := struct {
 		Zones        map[string]zoneSerial
 		Size         int
 		Age          string
 		Uptime       string
-		Hits         int
+		Hits         int64
 		RefreschRate int
+		Hooks        []hookDelivery `json:",omitempty"`
 	}{
 		Zones:        map[string]zoneSerial{},
-		Size:         dnsRR.Len(),
+		Size:         scopedSize(sc),
 		Age:          dnsRR.Age().String(),
 		Uptime:       dnsRR.Uptime().String(),
-		Hits:         dnsRR.APIhits,
+		Hits:         atomic.LoadInt64(&dnsRR.APIhits),
 		RefreschRate: config.TTL,
 	}
+	if sc.all() {
+		ret.Hooks = hooks.recent()
+	}
 
-	dnsRR.RLock()
-	for _, s := range dnsRR.soas {
-		z := s.Header().Name
-		ret.Zones[z] = zoneSerial{Serial: s.Serial}
+	for z, s := range zoneSerials(sc) {
+		ret.Zones[z] = zoneSerial{Serial: s, Age: dnsRR.ZoneAge(z).String()}
 	}
-	dnsRR.RUnlock()
 
-	j, err := json.Marshal(ret)
-	if err != nil {
-		log.Println("Error:", err)
-		os.Exit(1)
+	writeFlat(w, format, ret)
+}
+
+// scopedSize returns the number of names in the cache that sc sees.
+func scopedSize(sc *scope) int {
+	if sc.all() {
+		return dnsRR.Len()
 	}
+	names, _ := dnsRR.find(hostQuery{Scope: sc})
+	return len(names)
+}
 
-	fmt.Fprintf(w, string(j))
+// zoneSerials returns the serial of every zone in the cache that sc sees.
+func zoneSerials(sc *scope) map[string]uint32 {
+	ret := map[string]uint32{}
+	dnsRR.RLock()
+	defer dnsRR.RUnlock()
+	for _, s := range dnsRR.soas {
+		z := s.Header().Name
+		if sc.zone(z) {
+			ret[z] = s.Serial
+		}
+	}
+	return ret
 }
@@ -1,30 +1,44 @@
 package main
 
 import (
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
 )
 
 // cache is the structure for the dns cache, mutex and meta information regarding the cache.
 type cache struct {
-	data         map[string][]dns.RR // data is the dns cache
-	soas         []dns.SOA           // soas is domains/subdomains the cache will include
-	sync.RWMutex                     // RWMutex is read/write lock
-	age          time.Time           // age is the age of the cache.
-	startTime    time.Time           /// startTime is the time the server started
-	APIhits      int                 // hits is the number of questions the server have got.
+	data         map[string][]dns.RR          // data is the dns cache
+	zones        map[string]gethost.SOAwithRR // zones is the dns cache per zone, keyed by zone name
+	zoneAge      map[string]time.Time         // zoneAge is when each zone was loaded
+	soas         []dns.SOA                    // soas is domains/subdomains the cache will include
+	sync.RWMutex                              // RWMutex is read/write lock
+	age          time.Time                    // age is the age of the cache.
+	startTime    time.Time                    /// startTime is the time the server started
+	APIhits      int64                        // hits is the number of questions the server have got, use atomic.
+	generation   uint64                       // generation is incremented every time the cache is changed.
 }
 
 // Age returns the age of the cache. It should never get older than TTL from the config.
-func (c cache) Age() time.Duration {
+func (c *cache) Age() time.Duration {
 	c.RLock()
 	t := time.Since(c.age)
 	c.RUnlock()
 	return t.Truncate(time.Second)
 }
 
+// ZoneAge returns how long ago zone was loaded.
+func (c *cache) ZoneAge(zone string) time.Duration {
+	c.RLock()
+	t := time.Since(c.zoneAge[zone])
+	c.RUnlock()
+	return t.Truncate(time.Second)
+}
+
 // Len returns the lenth (size) of the cache. How many dns records it holds.
 func (c *cache) Len() int {
 	c.RLock()
@@ -33,8 +47,139 @@ func (c *cache) Len() int {
 	return n
 }
 
+// Generation returns the generation of the cache, that changes when the cache is changed.
+func (c *cache) Generation() uint64 {
+	c.RLock()
+	defer c.RUnlock()
+	return c.generation
+}
+
 // Uptime return uptime since start.
-func (c cache) Uptime() time.Duration {
+func (c *cache) Uptime() time.Duration {
 	t := time.Since(c.startTime)
 	return t.Truncate(time.Second)
 }
+
+// Loaded returns true when the cache has been loaded at least once.
+func (c *cache) Loaded() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return !c.age.IsZero()
+}
+
+// ExpiredZones returns the zones that are older than the expire time in their SOA,
+// and the number of zones in the cache.
+func (c *cache) ExpiredZones() ([]string, int) {
+	c.RLock()
+	defer c.RUnlock()
+	var expired []string
+	for z, d := range c.zones {
+		if d.SOA == nil {
+			continue
+		}
+		if time.Since(c.zoneAge[z]) > time.Duration(d.SOA.Expire)*time.Second {
+			expired = append(expired, z)
+		}
+	}
+	sort.Strings(expired)
+	return expired, len(c.zones)
+}
+
+// update puts the transferred zones in built in the cache, in addition to the zones already in it if
+// partial, and returns the change sets. The generation only changes when names or records change or
+// zones are added or removed. The first load of the cache is not a change.
+func (c *cache) update(built map[string]gethost.SOAwithRR, partial bool, now time.Time) []changeSet {
+	c.Lock()
+	defer c.Unlock()
+	zonesNew := built
+	zoneAge := map[string]time.Time{}
+	if partial {
+		zonesNew = map[string]gethost.SOAwithRR{}
+		for z, d := range c.zones {
+			zonesNew[z] = d
+			zoneAge[z] = c.zoneAge[z]
+		}
+		for z, d := range built {
+			zonesNew[z] = d
+		}
+	}
+	for z := range built {
+		zoneAge[z] = now
+	}
+	sets := diffZones(c.zones, zonesNew, now)
+	if len(sets) > 0 || !sameZones(c.zones, zonesNew) {
+		c.generation++
+	}
+	if c.age.IsZero() {
+		sets = nil
+	}
+	c.data, c.soas = mergeZones(zonesNew)
+	c.zones = zonesNew
+	c.zoneAge = zoneAge
+	c.age = now
+	return sets
+}
+
+// sameZones returns true if a and b have the same zones.
+func sameZones(a, b map[string]gethost.SOAwithRR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for z := range a {
+		if _, ok := b[z]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// retainZones removes all zones that are not in zones from the cache, and returns the change sets
+// that remove their names.
+func (c *cache) retainZones(zones []string) []changeSet {
+	keep := map[string]bool{}
+	for _, z := range zones {
+		keep[z] = true
+	}
+	c.Lock()
+	defer c.Unlock()
+	zonesNew := map[string]gethost.SOAwithRR{}
+	for z, d := range c.zones {
+		if keep[z] {
+			zonesNew[z] = d
+		}
+	}
+	if len(zonesNew) == len(c.zones) {
+		return nil
+	}
+	sets := diffZones(c.zones, zonesNew, time.Now())
+	for z := range c.zones {
+		if !keep[z] {
+			delete(c.zoneAge, z)
+		}
+	}
+	c.zones = zonesNew
+	c.data, c.soas = mergeZones(c.zones)
+	c.generation++
+	return sets
+}
+
+// dropZone removes zone from the cache, and returns the change sets that remove its names.
+func (c *cache) dropZone(zone string) []changeSet {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.zones[zone]; !ok {
+		return nil
+	}
+	zonesNew := map[string]gethost.SOAwithRR{}
+	for z, d := range c.zones {
+		if z != zone {
+			zonesNew[z] = d
+		}
+	}
+	sets := diffZones(c.zones, zonesNew, time.Now())
+	c.zones = zonesNew
+	delete(c.zoneAge, zone)
+	c.data, c.soas = mergeZones(c.zones)
+	c.generation++
+	return sets
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// uiPolicy keeps the web UI from loading anything but itself and the API.
+const uiPolicy = "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; img-src data:; " +
+	"connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// httpRoot sends browsers to the web UI.
+func httpRoot(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/ui/", http.StatusFound)
+}
+
+// httpUI serves the web UI. The page has no data of its own, it uses the JSON API with the token
+// the user gives, so it is public.
+func httpUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", uiPolicy)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	fmt.Fprint(w, uiPage)
+}
+
+// uiPage is the whole web UI. All data is put in the page with textContent, never as HTML.
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<link rel="icon" href="data:,">
+<title>gethost</title>
+<style>
+:root { --fg: #1d2125; --muted: #5f6b76; --bg: #fff; --line: #dde2e6; --sel: #e8f1fc; --err: #b3261e; }
+@media (prefers-color-scheme: dark) {
+  :root { --fg: #e3e6e8; --muted: #9aa5ae; --bg: #16191c; --line: #30363b; --sel: #1f3147; --err: #f2b8b5; }
+}
+* { box-sizing: border-box; }
+body { margin: 0; font: 15px/1.4 system-ui, sans-serif; color: var(--fg); background: var(--bg); }
+header { display: flex; flex-wrap: wrap; align-items: center; gap: 1em; padding: .6em 1em; border-bottom: 1px solid var(--line); }
+header h1 { font-size: 1.1em; margin: 0; }
+nav a { color: var(--muted); text-decoration: none; padding: .3em .6em; border-radius: 4px; }
+nav a.active { color: var(--fg); background: var(--sel); }
+#token-form { margin-left: auto; display: flex; gap: .4em; }
+main { padding: 1em; }
+input, select, button { font: inherit; color: var(--fg); background: var(--bg); padding: .3em .5em; border: 1px solid var(--line); border-radius: 4px; }
+button { cursor: pointer; }
+.search { display: flex; gap: .5em; margin-bottom: .5em; }
+.search input { flex: 1; font-size: 1.1em; padding: .4em .6em; }
+.split { display: grid; grid-template-columns: minmax(16em, 1fr) 2fr; gap: 1em; align-items: start; }
+@media (max-width: 50em) { .split { grid-template-columns: 1fr; } }
+#results { list-style: none; margin: 0; padding: 0; max-height: 75vh; overflow: auto; border: 1px solid var(--line); border-radius: 4px; }
+#results:empty { display: none; }
+#results li { display: flex; align-items: center; justify-content: space-between; gap: .5em; padding: .25em .6em; border-bottom: 1px solid var(--line); cursor: pointer; }
+#results li.selected { background: var(--sel); }
+.mono { font-family: ui-monospace, monospace; font-size: .92em; word-break: break-all; }
+.copy { font-size: .8em; padding: .05em .4em; }
+.muted { color: var(--muted); }
+.error { color: var(--err); }
+h2 { font-size: 1.1em; margin: 0 0 .5em; display: flex; gap: .5em; align-items: center; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { text-align: left; padding: .25em .6em; border-bottom: 1px solid var(--line); vertical-align: top; }
+th { font-weight: 600; }
+.hidden { display: none !important; }
+.offscreen { position: fixed; top: -100px; opacity: 0; }
+#toast { position: fixed; bottom: 1em; right: 1em; padding: .4em .8em; border-radius: 4px; background: var(--fg); color: var(--bg); opacity: 0; transition: opacity .2s; pointer-events: none; }
+#toast.show { opacity: .9; }
+</style>
+</head>
+<body>
+<header>
+  <h1>gethost</h1>
+  <nav><a href="#" id="nav-search">Search</a> <a href="#zones" id="nav-zones">Zones</a></nav>
+  <form id="token-form" class="hidden">
+    <input id="token" type="password" placeholder="Token" autocomplete="off" aria-label="Token">
+    <button>Use token</button>
+  </form>
+</header>
+<main>
+  <section id="search-view">
+    <form class="search" id="search-form" role="search">
+      <input id="q" type="search" placeholder="Part of a host name" autofocus autocomplete="off" spellcheck="false" aria-label="Host name">
+      <select id="mode" aria-label="Match mode">
+        <option value="auto">auto</option>
+        <option value="substring">substring</option>
+        <option value="prefix">prefix</option>
+        <option value="exact">exact</option>
+        <option value="tokens">tokens</option>
+      </select>
+    </form>
+    <p id="summary" class="muted"></p>
+    <div class="split">
+      <ul id="results"></ul>
+      <div id="detail"></div>
+    </div>
+  </section>
+  <section id="zones-view" class="hidden">
+    <p><span id="zones-summary" class="muted"></span> <button id="zones-refresh" type="button">Refresh</button></p>
+    <table>
+      <thead><tr><th>Zone</th><th>Serial</th><th>Loaded</th></tr></thead>
+      <tbody id="zones"></tbody>
+    </table>
+  </section>
+</main>
+<div id="toast" role="status"></div>
+<script>
+"use strict";
+(function () {
+  var limit = 200, delay = 150;
+  var token = localStorage.getItem("gethost-token") || "";
+  var names = [], selected = -1, pending = null, timer = null;
+
+  function $(id) { return document.getElementById(id); }
+
+  // el creates an element. Text is always set as text, so names can not inject HTML.
+  function el(tag, attrs, children) {
+    var e = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") e.textContent = attrs[k]; else e.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) {
+      e.appendChild(typeof c === "string" ? document.createTextNode(c) : c);
+    });
+    return e;
+  }
+
+  function api(path, signal) {
+    var headers = { "Accept": "application/json" };
+    if (token) headers["Authorization"] = "Bearer " + token;
+    return fetch(path, { headers: headers, signal: signal }).then(function (resp) {
+      return resp.json().catch(function () { return {}; }).then(function (body) {
+        if (resp.status === 401) {
+          $("token-form").classList.remove("hidden");
+          $("token").focus();
+        }
+        if (!resp.ok) throw new Error(body.error || resp.status + " " + resp.statusText);
+        return body;
+      });
+    });
+  }
+
+  function showError(target, err) {
+    target.textContent = err.message;
+    target.className = "error";
+  }
+
+  function toast(msg) {
+    var t = $("toast");
+    t.textContent = msg;
+    t.classList.add("show");
+    clearTimeout(toast.timer);
+    toast.timer = setTimeout(function () { t.classList.remove("show"); }, 1500);
+  }
+
+  // copyText uses the clipboard API where the browser allows it, which is only over HTTPS,
+  // and a selected text area otherwise.
+  function copyText(text) {
+    var done = function () { toast("Copied " + text); };
+    var fallback = function () {
+      var t = el("textarea", { "class": "offscreen", readonly: "" });
+      t.value = text;
+      document.body.appendChild(t);
+      t.select();
+      var ok = false;
+      try { ok = document.execCommand("copy"); } catch (e) {}
+      document.body.removeChild(t);
+      if (ok) done(); else toast("Could not copy");
+    };
+    if (navigator.clipboard && window.isSecureContext) {
+      navigator.clipboard.writeText(text).then(done, fallback);
+    } else {
+      fallback();
+    }
+  }
+
+  function copyButton(text) {
+    var b = el("button", { type: "button", "class": "copy", title: "Copy " + text, text: "Copy" });
+    b.addEventListener("click", function (ev) {
+      ev.stopPropagation();
+      copyText(text);
+    });
+    return b;
+  }
+
+  function search() {
+    var q = $("q").value.trim(), mode = $("mode").value;
+    var hash = q ? "#" + new URLSearchParams({ q: q, mode: mode }).toString() : location.pathname;
+    history.replaceState(null, "", hash);
+    if (pending) pending.abort();
+    if (q === "") {
+      names = [];
+      renderResults();
+      $("summary").textContent = "";
+      return;
+    }
+    pending = new AbortController();
+    var params = new URLSearchParams({ q: q, mode: mode, limit: String(limit) });
+    api("/v2/hosts?" + params.toString(), pending.signal).then(function (body) {
+      names = body.results || [];
+      selected = names.length ? 0 : -1;
+      renderResults();
+      var s = body.total + (body.total === 1 ? " match" : " matches");
+      if (body.total > names.length) s += ", showing the first " + names.length;
+      $("summary").textContent = s + ", cache loaded " + body.cache_age + "s ago";
+      $("summary").className = "muted";
+      if (names.length === 1) showHost(names[0]);
+    }).catch(function (err) {
+      if (err.name !== "AbortError") showError($("summary"), err);
+    });
+  }
+
+  function renderResults() {
+    var ul = $("results");
+    ul.textContent = "";
+    names.forEach(function (n, i) {
+      var li = el("li", { "class": i === selected ? "selected" : "" }, [el("span", { "class": "mono", text: n }), copyButton(n)]);
+      li.addEventListener("click", function () {
+        select(i);
+        showHost(n);
+      });
+      ul.appendChild(li);
+    });
+  }
+
+  function select(i) {
+    var items = $("results").children;
+    if (i < 0 || i >= items.length) return;
+    selected = i;
+    for (var j = 0; j < items.length; j++) items[j].classList.toggle("selected", j === i);
+    items[i].scrollIntoView({ block: "nearest" });
+  }
+
+  function row(label, value, copy) {
+    var cells = [el("td", { "class": "mono", text: value })];
+    if (copy) cells.push(el("td", {}, [copyButton(value)]));
+    return el("tr", {}, [el("th", { text: label })].concat(cells));
+  }
+
+  function showHost(name) {
+    var d = $("detail");
+    d.textContent = "";
+    d.appendChild(el("p", { "class": "muted", text: "Loading " + name }));
+    api("/hosts/" + encodeURIComponent(name) + "/records").then(function (h) {
+      d.textContent = "";
+      d.appendChild(el("h2", {}, [el("span", { "class": "mono", text: h.name }), copyButton(h.name)]));
+
+      var info = el("table");
+      info.appendChild(row("Zone", h.zone));
+      info.appendChild(row("Serial", String(h.serial)));
+      info.appendChild(row("Types", (h.types || []).join(", ")));
+      if (h.cname) info.appendChild(row("CNAME", h.cname, true));
+      (h.addresses || []).forEach(function (a) { info.appendChild(row("Address", a, true)); });
+      Object.keys(h.tags || {}).sort().forEach(function (k) {
+        info.appendChild(row("Tag " + k, h.tags[k]));
+      });
+      d.appendChild(info);
+
+      var records = el("table", {}, [el("thead", {}, [el("tr", {}, [
+        el("th", { text: "Type" }), el("th", { text: "TTL" }), el("th", { text: "Value" }), el("th")
+      ])])]);
+      var body = el("tbody");
+      (h.records || []).forEach(function (r) {
+        body.appendChild(el("tr", {}, [
+          el("td", { text: r.type }), el("td", { text: String(r.ttl) }),
+          el("td", { "class": "mono", text: r.value }), el("td", {}, [copyButton(r.value)])
+        ]));
+      });
+      records.appendChild(body);
+      d.appendChild(records);
+    }).catch(function (err) {
+      showError(d, err);
+    });
+  }
+
+  function loadZones() {
+    var summary = $("zones-summary");
+    api("/status").then(function (s) {
+      summary.textContent = s.Size + " names, cache loaded " + s.Age + " ago, refreshed every " +
+        s.RefreschRate + " s, server up " + s.Uptime + ", " + s.Hits + " searches.";
+      summary.className = "muted";
+      var tbody = $("zones");
+      tbody.textContent = "";
+      Object.keys(s.Zones || {}).sort().forEach(function (z) {
+        var v = s.Zones[z];
+        tbody.appendChild(el("tr", {}, [
+          el("td", { "class": "mono", text: z }), el("td", { text: String(v.serial) }), el("td", { text: (v.age || "?") + " ago" })
+        ]));
+      });
+    }).catch(function (err) {
+      showError(summary, err);
+    });
+  }
+
+  // route shows the view in the location hash, #zones or the search in #q=...&mode=...
+  function route() {
+    var h = location.hash.replace(/^#/, "");
+    var zones = h === "zones";
+    $("search-view").classList.toggle("hidden", zones);
+    $("zones-view").classList.toggle("hidden", !zones);
+    $("nav-search").classList.toggle("active", !zones);
+    $("nav-zones").classList.toggle("active", zones);
+    if (zones) {
+      loadZones();
+      return;
+    }
+    var p = new URLSearchParams(h);
+    if (p.has("q")) $("q").value = p.get("q");
+    if (p.has("mode")) $("mode").value = p.get("mode");
+    $("q").focus();
+    search();
+  }
+
+  $("q").addEventListener("input", function () {
+    clearTimeout(timer);
+    timer = setTimeout(search, delay);
+  });
+  $("q").addEventListener("keydown", function (ev) {
+    if (ev.key === "ArrowDown") select(selected + 1);
+    else if (ev.key === "ArrowUp") select(selected - 1);
+    else if (ev.key === "Enter" && names[selected]) showHost(names[selected]);
+    else return;
+    ev.preventDefault();
+  });
+  $("search-form").addEventListener("submit", function (ev) { ev.preventDefault(); });
+  $("mode").addEventListener("change", search);
+  $("zones-refresh").addEventListener("click", loadZones);
+  $("token-form").addEventListener("submit", function (ev) {
+    ev.preventDefault();
+    token = $("token").value.trim();
+    localStorage.setItem("gethost-token", token);
+    $("token").value = "";
+    $("token-form").classList.add("hidden");
+    route();
+  });
+  window.addEventListener("hashchange", route);
+  route();
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	gethost "github.com/spetzreborn/get_host/internal"
+)
+
+// Event types in the stream from /v2/watch.
+const (
+	eventHostAdded     = "host-added"
+	eventHostRemoved   = "host-removed"
+	eventHostChanged   = "host-changed"
+	eventZoneRefreshed = "zone-refreshed"
+	eventZoneFailed    = "zone-failed"
+	eventResync        = "resync" // eventResync tells that events were missed, and the names must be read again.
+)
+
+const (
+	watchBacklog   = 10000            // watchBacklog is the number of events kept for watchers that resume.
+	watchBuffer    = 256              // watchBuffer is the number of events a slow watcher may be behind before it is disconnected.
+	watchKeepAlive = 30 * time.Second // watchKeepAlive is how often a comment is sent to keep idle connections open.
+)
+
+var watchers = eventHub{subs: map[*watcher]bool{}}
+
+// event is one event in the stream from /v2/watch. Host events have Name, zone events do not.
+type event struct {
+	ID      int       `json:"id"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Zone    string    `json:"zone,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Serial  uint32    `json:"serial,omitempty"`
+	Records int       `json:"records,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// eventHub gives events to watchers, and keeps the latest events so watchers can resume.
+type eventHub struct {
+	sync.Mutex
+	events []event // events is the latest events, oldest first.
+	lastID int
+	subs   map[*watcher]bool
+	closed bool
+}
+
+// watcher is one subscriber. Its channel is closed when it is removed from the hub.
+type watcher struct {
+	c      chan event
+	filter func(event) bool
+}
+
+// publish gives events the next IDs and sends them to all watchers they match.
+// A watcher that is too far behind is disconnected, and may resume from its last event.
+func (h *eventHub) publish(events ...event) {
+	h.Lock()
+	defer h.Unlock()
+	for _, e := range events {
+		h.lastID++
+		e.ID = h.lastID
+		h.events = append(h.events, e)
+		for w := range h.subs {
+			if !w.filter(e) {
+				continue
+			}
+			select {
+			case w.c <- e:
+			default:
+				delete(h.subs, w)
+				close(w.c)
+			}
+		}
+	}
+	if len(h.events) > watchBacklog {
+		h.events = append([]event(nil), h.events[len(h.events)-watchBacklog:]...)
+	}
+}
+
+// subscribe adds a watcher and returns the events after lastID that match filter.
+// It returns true if events after lastID are no longer kept, or lastID is from before a restart.
+// The watcher is nil when the hub is closed.
+func (h *eventHub) subscribe(lastID int, filter func(event) bool) (*watcher, []event, bool) {
+	h.Lock()
+	defer h.Unlock()
+	if h.closed {
+		return nil, nil, false
+	}
+	w := &watcher{c: make(chan event, watchBuffer), filter: filter}
+	h.subs[w] = true
+	if lastID <= 0 {
+		return w, nil, false
+	}
+
+	resync := lastID > h.lastID || (len(h.events) > 0 && lastID < h.events[0].ID-1)
+	backlog := []event{}
+	for _, e := range h.events {
+		if e.ID > lastID && filter(e) {
+			backlog = append(backlog, e)
+		}
+	}
+	return w, backlog, resync
+}
+
+func (h *eventHub) unsubscribe(w *watcher) {
+	h.Lock()
+	defer h.Unlock()
+	if h.subs[w] {
+		delete(h.subs, w)
+		close(w.c)
+	}
+}
+
+// closeAll disconnects all watchers and refuses new ones, so the server can shut down.
+func (h *eventHub) closeAll() {
+	h.Lock()
+	defer h.Unlock()
+	h.closed = true
+	for w := range h.subs {
+		delete(h.subs, w)
+		close(w.c)
+	}
+}
+
+// changeEvents returns the host events of a change set.
+func changeEvents(cs changeSet) []event {
+	events := []event{}
+	for _, l := range []struct {
+		typ   string
+		names []string
+	}{{eventHostAdded, cs.Added}, {eventHostRemoved, cs.Removed}, {eventHostChanged, cs.Changed}} {
+		for _, n := range l.names {
+			events = append(events, event{Type: l.typ, Time: cs.Time, Zone: cs.Zone, Name: n, Serial: cs.Serial})
+		}
+	}
+	return events
+}
+
+// refreshEvents returns a zone-refreshed event for every zone in zones.
+func refreshEvents(zones map[string]gethost.SOAwithRR, now time.Time) []event {
+	names := make([]string, 0, len(zones))
+	for z := range zones {
+		names = append(names, z)
+	}
+	sort.Strings(names)
+	events := []event{}
+	for _, z := range names {
+		e := event{Type: eventZoneRefreshed, Time: now, Zone: z, Records: len(zones[z].RR)}
+		if zones[z].SOA != nil {
+			e.Serial = zones[z].SOA.Serial
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// watchFilter returns a filter of the events sc may see, where query and mode filter host events
+// as in /v2/hosts, and zones filters all events.
+func watchFilter(mode, query string, zones []string, sc *scope) (func(event) bool, error) {
+	match, err := gethost.Matcher(mode, query)
+	if err != nil {
+		return nil, err
+	}
+	zoneSet := map[string]bool{}
+	for _, z := range zones {
+		zoneSet[strings.ToLower(dns.Fqdn(z))] = true
+	}
+	return func(e event) bool {
+		if len(zoneSet) > 0 && !zoneSet[strings.ToLower(e.Zone)] {
+			return false
+		}
+		if e.Name == "" {
+			return sc.zone(e.Zone)
+		}
+		return sc.visible(e.Zone, e.Name) && match(e.Name)
+	}, nil
+}
+
+// writeEvent writes e in the Server-Sent Events format.
+func writeEvent(w http.ResponseWriter, e event) error {
+	j, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.ID > 0 {
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, j)
+	} else {
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, j)
+	}
+	return err
+}
+
+// httpWatch streams events as Server-Sent Events. The parameters q and mode filter host events
+// as in /v2/hosts, and zone filters all events. A client resumes with the Last-Event-ID header,
+// or the last_event_id parameter.
+func httpWatch(w http.ResponseWriter, r *http.Request, config *gethost.Config) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	params := r.URL.Query()
+	filter, err := watchFilter(params.Get("mode"), params.Get("q"), params["zone"], scopeFrom(r))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lastID := 0
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = params.Get("last_event_id")
+	}
+	if last != "" {
+		if lastID, err = strconv.Atoi(last); err != nil {
+			writeError(w, "bad last event id: "+last, http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub, backlog, resync := watchers.subscribe(lastID, filter)
+	if sub == nil {
+		writeError(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer watchers.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if resync {
+		writeEvent(w, event{Type: eventResync, Time: time.Now()})
+	}
+	for _, e := range backlog {
+		if writeEvent(w, e) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.c:
+			if !ok {
+				return
+			}
+			if writeEvent(w, e) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
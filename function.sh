@@ -9,45 +9,20 @@ db() {
 export -f db
 
 # ssh as given username - and send any normally arguments
-# check if ssh is up, and if so, ssh as the user in first argument to that host with eventually arguments.
+# The client resolves the host, lets you pick one if there are many and runs ssh.
+# Export SSHAS_WAIT, e.g. SSHAS_WAIT=10m, to also wait that long for the port to open.
 # Takes arguments in 'sshas [username] [remotehost] [extra arguments]'
 sshas() {
 	db "$FUNCNAME() was called, with args: $@"
-	db "Setting \$user to $1"
 	local user=$1
 	shift
-	db "Setting \$rhost to $1"
-	rhost=$1
+	local rhost=$1
 	shift
-	db "Setting \$args to $@"
-	args="$@"
-	db "Calling get_host()"
-	if get_host $rhost; then
-        db "Checking if \$args ($args) include -p"
-		if echo $args | egrep -q -- ' -p ' ;then
-			db "\$args do include -p, getting portnumber"
-			port=$(echo $args | egrep -o -- '-p [1-9]+' | awk '{print $2}')
-			db "port=$port"
-		else
-			db "\$args do not include -p, using standard port"
-			port=22
-		fi
-		db "Checking if port $port is open, wait until it is"
-		until nc -w 3 -z $rhost $port; do
-			echo $(date +'%Y-%m-%dT%H:%M:%S') " - port $port at $rhost not open yet . . ." 1>&2
-			read -t 5
-		done
-		db "Open, logging in:"
-		do_login="ssh ${user}@${rhost} $args"
-		db "$do_login"
-		eval $do_login
-
-        # Here things happen after ssh connection has been closed.
-		db "Logged out"
-	else
-		db "get_host() returned a bad value"
-		return 1
-		fi
+	local wait=()
+	if [ -n "$SSHAS_WAIT" ];then
+		wait=(-wait "$SSHAS_WAIT")
+	fi
+	~/client -configfile ~/example.toml ssh "${wait[@]}" "${user}@${rhost}" "$@"
 }
 
 s() {
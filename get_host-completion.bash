@@ -1,12 +1,5 @@
 #/usr/bin/env bash
 
-_get_host()
-{
-    if [ "${#COMP_WORDS[@]}" != "2" ]; then
-        return
-    fi
-    COMPREPLY=($(compgen -W "$(~/client -configfile ~/example.toml ${COMP_WORDS[COMP_CWORD]})" "${COMP_WORDS[1]}"))
-}
-
-complete -F _get_host get_host
-complete -F _get_host s
+# The completion is generated by the client, for the client itself and for the s and get_host functions
+# in function.sh. See "client completion" for other shells and other commands.
+. <(~/client -configfile ~/example.toml completion bash)
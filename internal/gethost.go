@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/miekg/dns"
@@ -30,9 +33,68 @@ type Config struct {
 	Resolver   string // TODO may be able to use resolver from configfile
 	TTL        int    // Timeout in seconds
 	ServerPort int    // Port for server to bind to
-	ServerURL  string // Url to server, used by client
+	GRPCPort   int    // Port for the gRPC service, disabled if 0 and GRPCListen is empty
+	ServerURL  string // Url to server, used by client, unix:/path/to/socket for a Unix domain socket
 	Tracing    bool   // Use jaeger tracing
 	Verbose    bool   // Print more verbose information
+
+	Listen      []string // Addresses the server listens on, host:port or unix:/path/to/socket, all interfaces on ServerPort if empty
+	GRPCListen  []string // Addresses the gRPC service listens on, as Listen, all interfaces on GRPCPort if empty
+	SocketMode  string   // File mode of Unix domain sockets in Listen and GRPCListen, in octal
+	SocketOwner string   // Owner of Unix domain sockets, "user", "user:group" or ":group"
+
+	ChangeLogSize      int    // Number of change sets the server keeps in memory
+	MinRefreshInterval int    // Seconds a zone is not transferred again when a refresh is forced with nc
+	AnnotationFile     string // File where the server persists annotations of names
+
+	ShutdownTimeout int    // Seconds the server waits for requests to finish when shutting down
+	ClientTimeout   int    // Milliseconds the client waits for the server
+	ClientCacheDir  string // Directory the client keeps responses in to revalidate with ETags, gethost in the user cache directory if empty, "-" disables
+
+	SearchLimit RateLimit // Requests per client to the searches, /hosts and /v2/hosts
+	StatusLimit RateLimit // Requests per client to /status, /changes and /metrics
+	ReloadLimit RateLimit // Forced reloads of the cache per client, with nc
+
+	TLSCertFile string // Certificate the server serves HTTPS with
+	TLSKeyFile  string // Key for TLSCertFile
+	TLSCAFile   string // CA bundle the server verifies client certificates with
+
+	ClientCertFile string // Client certificate the client presents to the server
+	ClientKeyFile  string // Key for ClientCertFile
+	ServerCAFile   string // CA bundle the client verifies the server with, the system CAs if empty
+
+	Token  string        // Bearer token the client sends to the server, GETHOST_TOKEN in the environment wins
+	Tokens []TokenConfig // Tokens the server accepts, if any the server requires a token
+	Hooks  []HookConfig  // Hooks the server fires when names change
+}
+
+// TokenConfig is a bearer token the server accepts, and what it may see.
+// A token without Zones and Patterns sees everything.
+type TokenConfig struct {
+	Name     string   // Name of the token, for logs
+	Hash     string   // Hash is hex encoded SHA-256 of the token
+	Zones    []string // Zones the token sees all names in
+	Patterns []string // Patterns of names the token sees, see path.Match
+	Admin    bool     // Admin tokens may use the admin API
+}
+
+// HookConfig is a webhook or a command that gets change sets after updates of the cache.
+// A hook without Zones and Patterns gets all changes.
+type HookConfig struct {
+	Name     string   // Name of the hook, for logs and status, URL or Command if not given
+	URL      string   // URL the change set is posted to as JSON
+	Secret   string   // Secret the body is signed with, HMAC-SHA256 in the header X-Gethost-Signature
+	Command  []string // Command that gets the change set as JSON on stdin, instead of URL
+	Zones    []string // Zones the hook fires for
+	Patterns []string // Patterns of names the hook fires for, see path.Match
+	Retries  int      // Times a failed webhook is retried with backoff, 3 if not given and negative is never
+	Timeout  int      // Seconds to wait for the webhook or command
+}
+
+// RateLimit is how many requests one client, an IP address or a token, may do.
+type RateLimit struct {
+	Rate  float64 // Requests per second, 0 is unlimited
+	Burst int     // Requests that may be done at once before Rate applies, at least 1
 }
 
 // NewConfig returns default configuration with consideration to configuration file.
@@ -42,23 +104,102 @@ func NewConfig(configFile *string) (*Config, error) {
 		ServerPort: 8080,
 		ServerURL:  "http://localhost",
 		Tracing:    false,
+
+		SocketMode: "0660",
+
+		ChangeLogSize:      1000,
+		MinRefreshInterval: 10,
+		ShutdownTimeout:    30,
+		ClientTimeout:      2000,
 	}
 	if _, err := toml.DecodeFile(*configFile, config); err != nil {
 		return nil, errors.New("toml decoding failed: " + err.Error())
 	}
+	for _, z := range config.Zones {
+		if dns.IsFqdn(z) == false {
+			return nil, errors.New("zone " + z + " Is not fully qualified. Maybe missing tailing '.'?")
+		}
+	}
+	for _, a := range append(append([]string{}, config.Listen...), config.GRPCListen...) {
+		if path, ok := SocketPath(a); ok {
+			if path == "" {
+				return nil, errors.New("listen address " + a + " has no path to the socket")
+			}
+		} else if _, _, err := net.SplitHostPort(a); err != nil {
+			return nil, errors.New("listen address " + a + " must be host:port or unix:/path/to/socket")
+		}
+	}
+	if _, err := strconv.ParseUint(config.SocketMode, 8, 32); err != nil {
+		return nil, errors.New("SocketMode " + config.SocketMode + " must be a file mode in octal, e.g. 0660")
+	}
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return nil, errors.New("both ClientCertFile and ClientKeyFile are required for a client certificate")
+	}
+	for _, l := range []*RateLimit{&config.SearchLimit, &config.StatusLimit, &config.ReloadLimit} {
+		if l.Rate < 0 || l.Burst < 0 {
+			return nil, errors.New("rate limits can not be negative")
+		}
+		if l.Burst < 1 {
+			l.Burst = 1
+		}
+	}
+	zones := map[string]bool{}
+	for _, z := range config.Zones {
+		zones[strings.ToLower(z)] = true
+	}
+	for _, t := range config.Tokens {
+		if len(t.Hash) != 64 {
+			return nil, errors.New("token " + t.Name + " must have Hash with hex encoded SHA-256")
+		}
+		for _, z := range t.Zones {
+			if !zones[dns.Fqdn(strings.ToLower(z))] {
+				return nil, errors.New("token " + t.Name + " has zone " + z + " that is not in Zones")
+			}
+		}
+	}
+	for i := range config.Hooks {
+		h := &config.Hooks[i]
+		if (h.URL == "") == (len(h.Command) == 0) {
+			return nil, errors.New("hook " + h.Name + " must have either URL or Command")
+		}
+		if h.Name == "" {
+			h.Name = h.URL
+			if h.Name == "" {
+				h.Name = strings.Join(h.Command, " ")
+			}
+		}
+		if h.Timeout < 0 {
+			return nil, errors.New("hook " + h.Name + " can not have negative Timeout")
+		}
+		if h.Retries == 0 {
+			h.Retries = 3
+		} else if h.Retries < 0 {
+			h.Retries = 0
+		}
+		if h.Timeout == 0 {
+			h.Timeout = 10
+		}
+	}
+	if token := os.Getenv("GETHOST_TOKEN"); token != "" {
+		config.Token = token
+	}
 	return config, nil
 }
 
+// SocketPath returns the path of a Unix domain socket address, unix:/path or unix:///path,
+// and false for other addresses.
+func SocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, "unix:") {
+		return "", false
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//"), true
+}
+
 // Zones returns a pointer to an slice with dns.SOA RR type for the zones to get AXFR from.
 func Zones(config *Config) []dns.SOA {
 	var soas = []dns.SOA{}
 	zones := config.Zones
 	for _, z := range zones {
-		isFqdn := dns.IsFqdn(z)
-		if isFqdn == false {
-			fmt.Println("zone " + z + " Is not fully qualified. Maybe missing tailing '.'?")
-			os.Exit(0)
-		}
 		soa := dns.SOA{}
 		soa.Header().Name = z
 		soas = append(soas, soa)
@@ -68,17 +209,22 @@ func Zones(config *Config) []dns.SOA {
 
 // GetRRforZoneResult is the return struct for GetRRforZone
 type GetRRforZoneResult struct {
-	SOA SOAwithRR
-	Err error
+	Zone     string // Zone is the zone that was transferred
+	NS       string // NS is the name server the zone was transferred from
+	SOA      SOAwithRR
+	Err      error
+	Duration time.Duration // Duration is how long the transfer took
 }
 
 // GetRRforZone send all CNAME and A records that match 'hostToGet' over channel c.
+// 'hostToGet' may hold several whitespace separated terms, see Match.
 // If 'hostToGet' is empty all CNAME and A records for zone z will be returned.
 // This function is well suited to be started in parallel as an go routine.
 func GetRRforZone(ctx context.Context, zone string, hostToGet string, c chan GetRRforZoneResult, config *Config) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "GetRRforZone")
 	span.SetTag("zone", zone)
 	defer span.Finish()
+	start := time.Now()
 
 	t := &dns.Transfer{}
 	m := &dns.Msg{}
@@ -93,7 +239,7 @@ func GetRRforZone(ctx context.Context, zone string, hostToGet string, c chan Get
 	}
 	if err != nil {
 		log.Println("GetRRforZone: Got error in NS from GetNSforZone:", err)
-		c <- GetRRforZoneResult{Err: err}
+		c <- GetRRforZoneResult{Zone: zone, Err: err, Duration: time.Since(start)}
 		return
 	}
 	if config.Verbose == true {
@@ -104,13 +250,16 @@ func GetRRforZone(ctx context.Context, zone string, hostToGet string, c chan Get
 		if config.Verbose == true {
 			log.Printf("GetRRforZone: Got error from %s:%s ", ns, err)
 		}
-		c <- GetRRforZoneResult{Err: err}
+		c <- GetRRforZoneResult{Zone: zone, NS: ns, Err: err, Duration: time.Since(start)}
 		return
 	}
 
 	dnsRR := SOAwithRR{}
 	dnsRR.RR = make(map[string][]dns.RR)
 	for envelope := range e { // Range read from channel e
+		if envelope.Error != nil && err == nil {
+			err = envelope.Error // Keep reading, the channel must be drained
+		}
 		for _, rr := range envelope.RR { // Iterate over all Resource Records
 			name := strings.TrimRight(rr.Header().Name, ".")
 			rrtype := rr.Header().Rrtype
@@ -121,7 +270,7 @@ func GetRRforZone(ctx context.Context, zone string, hostToGet string, c chan Get
 
 			if rrtype == dns.TypeA || rrtype == dns.TypeCNAME { // TODO should we save AAAA records also?
 				if hostToGet != "" {
-					if strings.Contains(name, hostToGet) {
+					if Match(name, hostToGet) {
 						tempSlice := dnsRR.RR[name]
 						dnsRR.RR[name] = append(tempSlice, rr)
 					}
@@ -132,14 +281,22 @@ func GetRRforZone(ctx context.Context, zone string, hostToGet string, c chan Get
 			}
 		}
 	}
-	ret := GetRRforZoneResult{SOA: dnsRR}
+	if err == nil && dnsRR.SOA == nil {
+		err = errors.New("no SOA in transfer of " + zone)
+	}
+	if err != nil {
+		log.Printf("GetRRforZone: Got error in transfer of %s from %s: %s\n", zone, ns, err)
+		c <- GetRRforZoneResult{Zone: zone, NS: ns, Err: err, Duration: time.Since(start)}
+		return
+	}
+	ret := GetRRforZoneResult{Zone: zone, NS: ns, SOA: dnsRR, Duration: time.Since(start)}
 	c <- ret
 	if config.Verbose == true {
 		log.Println("Done writing zone", zone)
 	}
 }
 
-//GetNSforZone returns NameServer for zone by doing NS query to the resolver configured in resolv.conf
+// GetNSforZone returns NameServer for zone by doing NS query to the resolver configured in resolv.conf
 func GetNSforZone(ctx context.Context, zone string) (ns string, err error) {
 	span, _ := opentracing.StartSpanFromContext(ctx, "GetNSforZone")
 	span.SetTag("zone", zone)
@@ -147,9 +304,8 @@ func GetNSforZone(ctx context.Context, zone string) (ns string, err error) {
 
 	// Get local resolver
 	conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-	if conf == nil {
-		fmt.Printf("Cannot initialize the local resolver: %s\n", err)
-		os.Exit(1)
+	if conf == nil || len(conf.Servers) == 0 {
+		return "", fmt.Errorf("Cannot initialize the local resolver: %v", err)
 	}
 
 	m := new(dns.Msg)
@@ -159,12 +315,12 @@ func GetNSforZone(ctx context.Context, zone string) (ns string, err error) {
 	if err != nil {
 		return "", err
 	}
-	if n, ok := in.Answer[0].(*dns.NS); ok {
-		ns = n.Ns
-	} else {
-		return "", errors.New("Did not get any NS record")
+	for _, rr := range in.Answer {
+		if n, ok := rr.(*dns.NS); ok {
+			return n.Ns, nil
+		}
 	}
-	return ns, nil
+	return "", errors.New("Did not get any NS record")
 }
 
 // JaegerInit initialises jaeger object.
@@ -0,0 +1,76 @@
+package gethost
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewConfigTokenZones(t *testing.T) {
+	tests := []struct {
+		zones string
+		err   string
+	}{
+		{`["example.tld."]`, ""},
+		{`["Example.TLD"]`, ""},
+		{`["other.tld."]`, "token t has zone other.tld. that is not in Zones"},
+	}
+	for _, tc := range tests {
+		f, err := ioutil.TempFile("", "gethost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		conf := `Zones = ["example.tld."]
+[[Tokens]]
+Name = "t"
+Hash = "` + strings.Repeat("0", 64) + `"
+Zones = ` + tc.zones + "\n"
+		if _, err := f.WriteString(conf); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		name := f.Name()
+		_, err = NewConfig(&name)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("token zones %s: %s", tc.zones, err)
+		case tc.err != "" && (err == nil || err.Error() != tc.err):
+			t.Errorf("token zones %s: got error %v, want %s", tc.zones, err, tc.err)
+		}
+	}
+}
+
+func TestNewConfigClientCert(t *testing.T) {
+	tests := []struct {
+		conf string
+		err  string
+	}{
+		{`ClientCertFile = "client.pem"` + "\n" + `ClientKeyFile = "client.key"`, ""},
+		{`TLSCertFile = "server.pem"` + "\n" + `TLSKeyFile = "server.key"`, ""},
+		{`ClientCertFile = "client.pem"`, "both ClientCertFile and ClientKeyFile are required for a client certificate"},
+		{`ClientKeyFile = "client.key"`, "both ClientCertFile and ClientKeyFile are required for a client certificate"},
+	}
+	for _, tc := range tests {
+		f, err := ioutil.TempFile("", "gethost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(`Zones = ["example.tld."]` + "\n" + tc.conf + "\n"); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		name := f.Name()
+		_, err = NewConfig(&name)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %s", tc.conf, err)
+		case tc.err != "" && (err == nil || err.Error() != tc.err):
+			t.Errorf("%s: got error %v, want %s", tc.conf, err, tc.err)
+		}
+	}
+}
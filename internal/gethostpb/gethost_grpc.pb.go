@@ -0,0 +1,251 @@
+// The gRPC service of the gethost server, enabled with GRPCPort in the configuration.
+// It answers from the same cache as the HTTP API, and accepts the same tokens as
+// "authorization: Bearer TOKEN" metadata.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: gethost.proto
+
+package gethostpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Gethost_Search_FullMethodName     = "/gethost.v1.Gethost/Search"
+	Gethost_GetRecords_FullMethodName = "/gethost.v1.Gethost/GetRecords"
+	Gethost_Status_FullMethodName     = "/gethost.v1.Gethost/Status"
+	Gethost_Watch_FullMethodName      = "/gethost.v1.Gethost/Watch"
+)
+
+// GethostClient is the client API for Gethost service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GethostClient interface {
+	// Search returns the names that match, like /v2/hosts.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// GetRecords returns the records of one name, like /hosts/{name}/records.
+	GetRecords(ctx context.Context, in *GetRecordsRequest, opts ...grpc.CallOption) (*Host, error)
+	// Status returns the zones and size of the cache, like /status.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// Watch streams changes of the cache, like /v2/watch.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type gethostClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGethostClient(cc grpc.ClientConnInterface) GethostClient {
+	return &gethostClient{cc}
+}
+
+func (c *gethostClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Gethost_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gethostClient) GetRecords(ctx context.Context, in *GetRecordsRequest, opts ...grpc.CallOption) (*Host, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Host)
+	err := c.cc.Invoke(ctx, Gethost_GetRecords_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gethostClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, Gethost_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gethostClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Gethost_ServiceDesc.Streams[0], Gethost_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Gethost_WatchClient = grpc.ServerStreamingClient[Event]
+
+// GethostServer is the server API for Gethost service.
+// All implementations must embed UnimplementedGethostServer
+// for forward compatibility.
+type GethostServer interface {
+	// Search returns the names that match, like /v2/hosts.
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// GetRecords returns the records of one name, like /hosts/{name}/records.
+	GetRecords(context.Context, *GetRecordsRequest) (*Host, error)
+	// Status returns the zones and size of the cache, like /status.
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// Watch streams changes of the cache, like /v2/watch.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedGethostServer()
+}
+
+// UnimplementedGethostServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGethostServer struct{}
+
+func (UnimplementedGethostServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedGethostServer) GetRecords(context.Context, *GetRecordsRequest) (*Host, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetRecords not implemented")
+}
+func (UnimplementedGethostServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedGethostServer) Watch(*WatchRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedGethostServer) mustEmbedUnimplementedGethostServer() {}
+func (UnimplementedGethostServer) testEmbeddedByValue()                 {}
+
+// UnsafeGethostServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GethostServer will
+// result in compilation errors.
+type UnsafeGethostServer interface {
+	mustEmbedUnimplementedGethostServer()
+}
+
+func RegisterGethostServer(s grpc.ServiceRegistrar, srv GethostServer) {
+	// If the following call panics, it indicates UnimplementedGethostServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Gethost_ServiceDesc, srv)
+}
+
+func _Gethost_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GethostServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gethost_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GethostServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gethost_GetRecords_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecordsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GethostServer).GetRecords(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gethost_GetRecords_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GethostServer).GetRecords(ctx, req.(*GetRecordsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gethost_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GethostServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gethost_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GethostServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gethost_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GethostServer).Watch(m, &grpc.GenericServerStream[WatchRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Gethost_WatchServer = grpc.ServerStreamingServer[Event]
+
+// Gethost_ServiceDesc is the grpc.ServiceDesc for Gethost service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Gethost_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gethost.v1.Gethost",
+	HandlerType: (*GethostServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler:    _Gethost_Search_Handler,
+		},
+		{
+			MethodName: "GetRecords",
+			Handler:    _Gethost_GetRecords_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Gethost_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Gethost_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gethost.proto",
+}
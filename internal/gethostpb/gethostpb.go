@@ -0,0 +1,5 @@
+// Package gethostpb is the gRPC service of the gethost server, generated from gethost.proto
+// in the root of the repository.
+package gethostpb
+
+//go:generate protoc -I ../.. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gethost.proto
@@ -0,0 +1,156 @@
+package gethost
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Match modes for Matcher.
+const (
+	ModeAuto      = "auto"      // ModeAuto is the same as Match.
+	ModeSubstring = "substring" // ModeSubstring matches names that contain the query.
+	ModePrefix    = "prefix"    // ModePrefix matches names that begin with the query.
+	ModeExact     = "exact"     // ModeExact matches the name, with or without trailing dot.
+	ModeTokens    = "tokens"    // ModeTokens matches every term against tokens, see MatchTokens.
+)
+
+// Modes is all match modes, ModeAuto first as it is the default.
+var Modes = []string{ModeAuto, ModeSubstring, ModePrefix, ModeExact, ModeTokens}
+
+// Matcher returns a function that reports if a name matches query in mode.
+// An empty mode is ModeAuto, and an empty query matches all names in every mode.
+// Every mode ignores case, as DNS does, and whitespace around the query.
+func Matcher(mode string, query string) (func(name string) bool, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return func(string) bool { return true }, nil
+	}
+	switch mode {
+	case "", ModeAuto:
+		return func(name string) bool { return Match(name, query) }, nil
+	case ModeSubstring:
+		q := strings.ToLower(query)
+		return func(name string) bool { return strings.Contains(strings.ToLower(name), q) }, nil
+	case ModePrefix:
+		q := strings.ToLower(query)
+		return func(name string) bool { return strings.HasPrefix(strings.ToLower(name), q) }, nil
+	case ModeExact:
+		q := strings.TrimRight(query, ".")
+		return func(name string) bool { return strings.EqualFold(strings.TrimRight(name, "."), q) }, nil
+	case ModeTokens:
+		terms := Terms(query)
+		return func(name string) bool { return MatchTokens(Tokenize(name), terms) }, nil
+	}
+	return nil, fmt.Errorf("unknown match mode %q, use one of %s", mode, strings.Join(Modes, ", "))
+}
+
+// Tokenize splits a host name into lower case tokens on '.', '-', '_' and on
+// boundaries between digits and non-digits.
+// "prod-web-12.dc2.example.tld" gives [prod web 12 dc 2 example tld].
+func Tokenize(name string) []string {
+	var (
+		tokens []string
+		cur    []rune
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			tokens = append(tokens, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '.' || r == '-' || r == '_' || unicode.IsSpace(r):
+			flush()
+		case len(cur) > 0 && unicode.IsDigit(r) != unicode.IsDigit(cur[len(cur)-1]):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// Terms splits a query into its whitespace separated terms.
+func Terms(query string) []string {
+	return strings.Fields(query)
+}
+
+// Match reports whether name matches query, ignoring case and whitespace around the query.
+// A query with a single term matches if it is a substring of name, as it always has.
+// A query with several whitespace separated terms matches if every term, in any order,
+// matches a run of tokens in name, see MatchTokens.
+func Match(name string, query string) bool {
+	terms := Terms(query)
+	if len(terms) < 2 {
+		return strings.Contains(strings.ToLower(name), strings.ToLower(strings.TrimSpace(query)))
+	}
+	return MatchTokens(Tokenize(name), terms)
+}
+
+// MatchTokens reports whether every term matches a run of consecutive tokens in nameTokens.
+// A term is itself tokenized. All term tokens must be equal to the name tokens,
+// except the last one that only needs to be a prefix, so "dc2" matches "dc2" and "dc-21".
+func MatchTokens(nameTokens []string, terms []string) bool {
+	for _, term := range terms {
+		if !matchTerm(nameTokens, Tokenize(term)) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTerm(nameTokens []string, termTokens []string) bool {
+	if len(termTokens) == 0 {
+		return true
+	}
+	last := len(termTokens) - 1
+	for i := 0; i+last < len(nameTokens); i++ {
+		ok := true
+		for j, t := range termTokens {
+			n := nameTokens[i+j]
+			if j == last {
+				ok = strings.HasPrefix(n, t)
+			} else {
+				ok = n == t
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PlainName returns name with whitespace and control characters written as \DDD, as in zone files,
+// so that every name is one line and one shell word. Other escapes are kept as they are.
+func PlainName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '\\' && i+1 < len(name) && !isPlain(name[i+1]):
+			fmt.Fprintf(&b, "\\%03d", name[i+1])
+			i++
+		case c == '\\' && i+1 < len(name):
+			b.WriteByte(c)
+			b.WriteByte(name[i+1])
+			i++
+		case !isPlain(c):
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isPlain(c byte) bool {
+	return c > ' ' && c < 0x7f
+}
@@ -0,0 +1,129 @@
+package gethost
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"prod-web-12.dc2.example.tld", []string{"prod", "web", "12", "dc", "2", "example", "tld"}},
+		{"PROD_Web12.", []string{"prod", "web", "12"}},
+		{"a..b--c", []string{"a", "b", "c"}},
+		{"web 1", []string{"web", "1"}},
+		{"", nil},
+		{"...", nil},
+	}
+	for _, tc := range tests {
+		if got := Tokenize(tc.name); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"web-1.example.tld", "web", true},
+		{"web-1.example.tld", "WEB", true},
+		{"WEB-1.example.tld", "web-1.ex", true},
+		{"web-1.example.tld", "db", false},
+		{"web-1.example.tld", "eb-1", true},
+		{"web-1.example.tld", "web 1", true},
+		{"web-1.example.tld", "WEB 1", true},
+		{"web-1.example.tld", "1 web", true},
+		{"web-1.example.tld", "eb 1", false},
+		{"web-1.example.tld", "web 2", false},
+		{"prod-web-12.dc2.example.tld", "web dc2", true},
+		{"prod-web-12.dc2.example.tld", "prod-web 12", true},
+		{"prod-web-12.dc2.example.tld", "web dc3", false},
+		{"web-1.example.tld", " web", true},
+		{"web-1.example.tld", "Web-1 ", true},
+		{"web-1.example.tld", "\tweb\n", true},
+	}
+	for _, tc := range tests {
+		if got := Match(tc.name, tc.query); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.name, tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestMatchTokens(t *testing.T) {
+	name := Tokenize("prod-web-12.dc2.example.tld")
+	tests := []struct {
+		terms []string
+		want  bool
+	}{
+		{nil, true},
+		{[]string{"web"}, true},
+		{[]string{"we"}, true},
+		{[]string{"eb"}, false},
+		{[]string{"dc2"}, true},
+		{[]string{"dc"}, true},
+		{[]string{"dc-2"}, true},
+		{[]string{"web-1"}, true},
+		{[]string{"prod-we"}, true},
+		{[]string{"pro-web"}, false},
+		{[]string{"web", "DC2", "tld"}, true},
+		{[]string{"web", "dc3"}, false},
+		{[]string{"example.tld"}, true},
+		{[]string{"tld.example"}, false},
+	}
+	for _, tc := range tests {
+		if got := MatchTokens(name, tc.terms); got != tc.want {
+			t.Errorf("MatchTokens(%q, %q) = %v, want %v", name, tc.terms, got, tc.want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		mode  string
+		query string
+		name  string
+		want  bool
+	}{
+		{"", "web", "web-1.example.tld", true},
+		{ModeAuto, "WEB 1", "web-1.example.tld", true},
+		{ModeAuto, "Web", "web-1.example.tld", true},
+		{ModeAuto, "db", "web-1.example.tld", false},
+		{ModeSubstring, "EB-1", "web-1.example.tld", true},
+		{ModeSubstring, "eb-1", "WEB-1.example.tld", true},
+		{ModeSubstring, "web 1", "web-1.example.tld", false},
+		{ModePrefix, "WEB-", "web-1.example.tld", true},
+		{ModePrefix, "web-", "Web-1.example.tld", true},
+		{ModePrefix, "eb", "web-1.example.tld", false},
+		{ModeExact, "WEB-1.example.tld.", "web-1.example.tld", true},
+		{ModeExact, "web-1.example.tld", "web-1.Example.tld.", true},
+		{ModeExact, "web-1", "web-1.example.tld", false},
+		{ModeTokens, "WEB 1", "web-1.example.tld", true},
+		{ModeTokens, "web", "prod-web-12.example.tld", true},
+		{ModeTokens, "eb", "prod-web-12.example.tld", false},
+		{ModeSubstring, "", "anything", true},
+		{ModeExact, "  ", "anything", true},
+		{"", " web ", "web-1.example.tld", true},
+		{ModeSubstring, " eb-1", "web-1.example.tld", true},
+		{ModePrefix, "web- ", "web-1.example.tld", true},
+		{ModeExact, " web-1.example.tld. ", "web-1.example.tld", true},
+	}
+	for _, tc := range tests {
+		match, err := Matcher(tc.mode, tc.query)
+		if err != nil {
+			t.Errorf("Matcher(%q, %q): %v", tc.mode, tc.query, err)
+			continue
+		}
+		if got := match(tc.name); got != tc.want {
+			t.Errorf("Matcher(%q, %q)(%q) = %v, want %v", tc.mode, tc.query, tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := Matcher("bogus", "web"); err == nil {
+		t.Error("Matcher with unknown mode did not fail")
+	}
+}